@@ -0,0 +1,356 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v6"
+)
+
+// AffectedOptions configures which changes AffectedPackages considers.
+type AffectedOptions struct {
+	// SinceDefaultBranch also considers the changes committed since the merge base of HEAD and the default branch,
+	// not only the uncommitted changes of the working tree.
+	SinceDefaultBranch bool
+}
+
+// AffectedPackages are the packages of the repository affected by changed files.
+type AffectedPackages struct {
+	// Changed are the changed files, relative to the repository root, sorted.
+	Changed []string
+	// Modules are the affected and unaffected packages per module.
+	Modules []AffectedModule
+}
+
+// AffectedModule are the affected and unaffected packages of a module.
+type AffectedModule struct {
+	// Module is the module of the repository.
+	Module GoModule
+	// All is true if a change affects every package, i.e. go.mod, go.sum, the vendor directory or go.work changed.
+	All bool
+	// Affected are the import paths of the packages whose files or in-module dependencies changed,
+	// including those whose tests import a changed package, sorted.
+	Affected []string
+	// Skipped are the import paths of the unaffected packages, sorted.
+	Skipped []string
+
+	// dirs are the package directories relative to the module directory, by import path.
+	dirs map[string]string
+}
+
+// patterns returns the package patterns of the affected packages relative to the module directory,
+// e.g. "./pkg", which go and golangci-lint both accept.
+func (m AffectedModule) patterns() []string {
+	patterns := make([]string, len(m.Affected))
+	for i, pkg := range m.Affected {
+		patterns[i] = "."
+		if dir := m.dirs[pkg]; dir != "." {
+			patterns[i] = "./" + filepath.ToSlash(dir)
+		}
+	}
+
+	return patterns
+}
+
+// Skipped returns the import paths of the unaffected packages of all modules.
+func (a *AffectedPackages) Skipped() []string {
+	skipped := []string{}
+	for _, m := range a.Modules {
+		skipped = append(skipped, m.Skipped...)
+	}
+
+	return skipped
+}
+
+// Affected returns the import paths of the affected packages of all modules.
+func (a *AffectedPackages) Affected() []string {
+	affected := []string{}
+	for _, m := range a.Modules {
+		affected = append(affected, m.Affected...)
+	}
+
+	return affected
+}
+
+// listedPackage is the output of `go list -json` for a package.
+type listedPackage struct {
+	Dir        string
+	ImportPath string
+	Module     *struct {
+		Path string
+	}
+	GoFiles         []string
+	Imports         []string
+	TestImports     []string
+	XTestImports    []string
+	EmbedFiles      []string
+	TestEmbedFiles  []string
+	XTestEmbedFiles []string
+}
+
+// AffectedPackages returns the packages of every module affected by the changed files: packages with a changed file,
+// embedded file or test data, and packages that import them, directly or transitively within the module,
+// including through their tests. A change of go.mod, go.sum or the vendor directory of a module affects all of its
+// packages, and a change of go.work those of all modules. The import graph is taken from `go list -deps -json`.
+func (r Repository) AffectedPackages(ctx context.Context, opts AffectedOptions) (*AffectedPackages, error) {
+	changed, err := r.changedRepoFiles(opts.SinceDefaultBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := r.root()
+	if err != nil {
+		return nil, err
+	}
+
+	workspaceChanged := slices.ContainsFunc(changed, func(name string) bool {
+		return name == "go.work" || name == "go.work.sum"
+	})
+
+	affected := &AffectedPackages{Changed: changed, Modules: []AffectedModule{}}
+	if err := r.forEachModule(func(m GoModule) error {
+		pkgs, err := r.modulePackages(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		am, err := affectedModule(m, root, pkgs, changed)
+		if err != nil {
+			return err
+		}
+
+		if workspaceChanged {
+			am.All = true
+			am.Affected, am.Skipped = append(am.Affected, am.Skipped...), []string{}
+			slices.Sort(am.Affected)
+		}
+
+		affected.Modules = append(affected.Modules, am)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return affected, nil
+}
+
+// modulePackages returns the packages of the module, as listed by `go list -deps -json`
+// with the given flags, e.g. build tags. Dependencies outside of the module are left out.
+func (r Repository) modulePackages(ctx context.Context, m GoModule, flags ...string) ([]listedPackage, error) {
+	out, err := r.outputIn(ctx, m.Dir, "go", slices.Concat([]string{"list", "-deps", "-json"}, flags, []string{"./..."})...)
+	if err != nil {
+		return nil, err
+	}
+
+	listed, err := decodeJSONStream[listedPackage](out)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := []listedPackage{}
+	for _, p := range listed {
+		if p.Module != nil && p.Module.Path == m.Path {
+			pkgs = append(pkgs, p)
+		}
+	}
+
+	return pkgs, nil
+}
+
+// changedRepoFiles returns the uncommitted changes and, if sinceDefaultBranch is set,
+// the changes since the merge base of HEAD and the default branch.
+func (r Repository) changedRepoFiles(sinceDefaultBranch bool) ([]string, error) {
+	if !sinceDefaultBranch {
+		changed, err := r.GetChangedFiles()
+		if err != nil {
+			return nil, err
+		}
+
+		slices.Sort(changed)
+
+		return changed, nil
+	}
+
+	root, err := r.root()
+	if err != nil {
+		return nil, err
+	}
+
+	gr, err := git.PlainOpen(root)
+	if err != nil {
+		return nil, fmt.Errorf("opening git repository: %w", err)
+	}
+
+	base, err := mergeBase(gr)
+	if err != nil {
+		return nil, err
+	}
+
+	baseTree, err := base.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("getting tree of merge base: %w", err)
+	}
+
+	return r.changedPaths(gr, baseTree)
+}
+
+// affectedModule determines the packages of the module affected by the changed files,
+// given the packages of the module as listed by the go command.
+func affectedModule(m GoModule, root string, pkgs []listedPackage, changed []string) (AffectedModule, error) {
+	am := AffectedModule{Module: m, Affected: []string{}, Skipped: []string{}, dirs: map[string]string{}}
+
+	modDir := path.Clean(filepath.ToSlash(m.Dir))
+	inModule := func(name string) (string, bool) {
+		if modDir == "." {
+			return name, true
+		}
+
+		return strings.CutPrefix(name, modDir+"/")
+	}
+
+	for _, name := range changed {
+		rel, ok := inModule(name)
+		if ok && (rel == goModFile || rel == "go.sum" || strings.HasPrefix(rel, "vendor/")) {
+			am.All = true
+		}
+	}
+
+	byPath := map[string]listedPackage{}
+	direct := map[string]bool{}
+
+	for _, p := range pkgs {
+		dir, err := filepath.Rel(filepath.Join(root, m.Dir), p.Dir)
+		if err != nil {
+			return am, err
+		}
+
+		byPath[p.ImportPath] = p
+		am.dirs[p.ImportPath] = dir
+		direct[p.ImportPath] = am.All || slices.ContainsFunc(changed, func(name string) bool {
+			rel, ok := inModule(name)
+			return ok && packageFile(filepath.ToSlash(dir), p, rel)
+		})
+	}
+
+	// changedDeep reports whether the package or one of its in-module dependencies changed
+	memo := map[string]bool{}
+
+	var changedDeep func(pkg string) bool
+	changedDeep = func(pkg string) bool {
+		if v, ok := memo[pkg]; ok {
+			return v
+		}
+
+		memo[pkg] = false // imports are acyclic, but guard anyway
+
+		p, ok := byPath[pkg]
+		if !ok {
+			return false // not in the module
+		}
+
+		v := direct[pkg] || slices.ContainsFunc(p.Imports, changedDeep)
+		memo[pkg] = v
+
+		return v
+	}
+
+	for _, p := range pkgs {
+		if changedDeep(p.ImportPath) ||
+			slices.ContainsFunc(p.TestImports, changedDeep) || slices.ContainsFunc(p.XTestImports, changedDeep) {
+			am.Affected = append(am.Affected, p.ImportPath)
+		} else {
+			am.Skipped = append(am.Skipped, p.ImportPath)
+		}
+	}
+
+	slices.Sort(am.Affected)
+	slices.Sort(am.Skipped)
+
+	return am, nil
+}
+
+// packageFile reports whether the file, relative to the module directory, belongs to the package in dir:
+// a file in its directory, a file in its testdata directory, or one of its embedded files.
+func packageFile(dir string, p listedPackage, name string) bool {
+	if path.Dir(name) == dir || strings.HasPrefix(name, path.Join(dir, "testdata")+"/") {
+		return true
+	}
+
+	for _, files := range [][]string{p.EmbedFiles, p.TestEmbedFiles, p.XTestEmbedFiles} {
+		for _, f := range files {
+			if path.Join(dir, f) == name {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AffectedCheckOptions configures CheckAffected.
+type AffectedCheckOptions struct {
+	AffectedOptions
+	// Test configures how the tests are run. Its packages are replaced by the affected ones.
+	Test TestOptions
+	// SkipLint does not run golangci-lint, e.g. if it is not installed.
+	SkipLint bool
+}
+
+// AffectedCheck is the result of CheckAffected.
+type AffectedCheck struct {
+	// Packages are the affected packages that were checked and the unaffected ones that were skipped.
+	Packages *AffectedPackages
+	// Tests is the result of the tests of the affected packages.
+	Tests *TestReport
+}
+
+// CheckAffected runs go vet, golangci-lint and the tests only on the packages affected by the changes,
+// see AffectedPackages. Like Test, failing tests do not cause an error, but failures of go vet or golangci-lint do.
+// All modules are checked even if one of them fails.
+func (r Repository) CheckAffected(ctx context.Context, opts AffectedCheckOptions) (*AffectedCheck, error) {
+	affected, err := r.AffectedPackages(ctx, opts.AffectedOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &AffectedCheck{Packages: affected, Tests: &TestReport{Seed: opts.Test.shuffleSeed()}}
+
+	errs := []error{}
+	for _, am := range affected.Modules {
+		if len(am.Affected) == 0 {
+			continue
+		}
+
+		pkgs := am.patterns()
+
+		if err := r.goVet(ctx, am.Module, pkgs...); err != nil {
+			errs = append(errs, ModuleError{Module: am.Module, Err: err})
+		}
+
+		if !opts.SkipLint {
+			if err := r.golangCILintIn(ctx, am.Module, append([]string{"run"}, pkgs...)...); err != nil {
+				errs = append(errs, ModuleError{Module: am.Module, Err: err})
+			}
+		}
+
+		testOpts := opts.Test
+		testOpts.Packages = pkgs
+
+		report, err := r.testModule(ctx, am.Module, testOpts, check.Tests.Seed)
+		if report != nil {
+			check.Tests.Packages = append(check.Tests.Packages, report.Packages...)
+		}
+
+		if err != nil {
+			errs = append(errs, ModuleError{Module: am.Module, Err: err})
+		}
+	}
+
+	return check, errors.Join(errs...)
+}
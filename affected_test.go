@@ -0,0 +1,116 @@
+package gorepo
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestPackageFile(t *testing.T) {
+	p := listedPackage{EmbedFiles: []string{"static/index.html"}}
+	for name, want := range map[string]bool{
+		"a/a.go":                true,
+		"a/README.md":           true,
+		"a/testdata/in.txt":     true,
+		"a/static/index.html":   true,
+		"a/static/other.html":   false,
+		"a/sub/s.go":            false,
+		"ab/b.go":               false,
+		"a/testdatax/other.txt": false,
+	} {
+		if got := packageFile("a", p, name); got != want {
+			t.Errorf("packageFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestAffectedModule(t *testing.T) {
+	m := GoModule{Dir: "mod", Path: "example.com/m"}
+	pkgs := []listedPackage{
+		{ImportPath: "example.com/m/base", Dir: "/repo/mod/base"},
+		{ImportPath: "example.com/m/mid", Dir: "/repo/mod/mid", Imports: []string{"example.com/m/base", "fmt"}},
+		{ImportPath: "example.com/m/top", Dir: "/repo/mod/top", Imports: []string{"example.com/m/mid"}},
+		{ImportPath: "example.com/m/tested", Dir: "/repo/mod/tested", XTestImports: []string{"example.com/m/base"}},
+		{ImportPath: "example.com/m/other", Dir: "/repo/mod/other"},
+	}
+
+	am, err := affectedModule(m, "/repo", pkgs, []string{"README.md", "mod/base/b.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"example.com/m/base", "example.com/m/mid", "example.com/m/tested", "example.com/m/top"}; !slices.Equal(am.Affected, want) {
+		t.Errorf("Affected = %v, want %v", am.Affected, want)
+	}
+
+	if want := []string{"example.com/m/other"}; !slices.Equal(am.Skipped, want) || am.All {
+		t.Errorf("Skipped = %v, All = %v", am.Skipped, am.All)
+	}
+
+	if got := am.patterns(); got[0] != "./base" {
+		t.Errorf("patterns() = %v", got)
+	}
+
+	am, err = affectedModule(m, "/repo", pkgs, []string{"mod/go.sum"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !am.All || len(am.Affected) != len(pkgs) || len(am.Skipped) != 0 {
+		t.Errorf("go.sum change did not affect everything: %+v", am)
+	}
+}
+
+func TestCheckAffected(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":         "module example.com/m\n\ngo 1.24\n",
+		"m.go":           "package m\n\nimport _ \"example.com/m/a\"\n",
+		"a/a.go":         "package a\n\nfunc A() int { return 1 }\n",
+		"a/a_test.go":    "package a\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) {}\n",
+		"b/b.go":         "package b\n\nfunc B() int { return 2 }\n",
+		"b/b_test.go":    "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) {}\n",
+		"c/c_test.go":    "package c_test\n\nimport (\n\t\"testing\"\n\n\t\"example.com/m/a\"\n)\n\nfunc TestC(t *testing.T) { a.A() }\n",
+		"c/c.go":         "package c\n",
+		"docs/README.md": "docs\n",
+	})
+
+	if err := repo.CommitAll("initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFiles(t, repo, map[string]string{"a/a.go": "package a\n\nfunc A() int { return 3 }\n"})
+
+	check, err := repo.CheckAffected(context.Background(), AffectedCheckOptions{SkipLint: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"example.com/m", "example.com/m/a", "example.com/m/c"}; !slices.Equal(check.Packages.Affected(), want) {
+		t.Errorf("Affected() = %v, want %v", check.Packages.Affected(), want)
+	}
+
+	if want := []string{"example.com/m/b"}; !slices.Equal(check.Packages.Skipped(), want) {
+		t.Errorf("Skipped() = %v, want %v", check.Packages.Skipped(), want)
+	}
+
+	if _, ok := check.Tests.Test("example.com/m/b", "TestB"); ok {
+		t.Error("tests of unaffected package were run")
+	}
+
+	for _, pkg := range []string{"example.com/m/a", "example.com/m/c"} {
+		if p, ok := check.Tests.Package(pkg); !ok || p.Status != TestPass {
+			t.Errorf("package %s: %+v", pkg, p)
+		}
+	}
+
+	// a vet failure in an affected package is reported
+	writeTestFiles(t, repo, map[string]string{"a/a.go": "package a\n\nimport \"fmt\"\n\nfunc A() int { fmt.Printf(\"%d\"); return 3 }\n"})
+
+	if _, err := repo.CheckAffected(context.Background(), AffectedCheckOptions{SkipLint: true}); err == nil {
+		t.Error("expected go vet to fail")
+	}
+}
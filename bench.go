@@ -0,0 +1,570 @@
+package gorepo
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MarkRosemaker/ghrepo"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/spf13/afero"
+)
+
+const (
+	// defaultBenchCount is how often every benchmark runs if the options do not set a count,
+	// enough samples for a meaningful comparison.
+	defaultBenchCount = 6
+	// defaultBenchAlpha is the significance level of benchmark comparisons if none is given.
+	defaultBenchAlpha = 0.05
+)
+
+// BenchOptions configures how benchmarks are run by Bench.
+// The embedded test options apply as well, but Run defaults to "^$" so that no tests run
+// and Count defaults to 6 so that there are enough samples to compare.
+type BenchOptions struct {
+	TestOptions
+	// Bench runs the benchmarks matching the regular expression, "." (all) if empty.
+	Bench string
+	// Benchtime is how long or, e.g. "100x", how often every benchmark runs per sample. Empty keeps the default of 1s.
+	Benchtime string
+}
+
+// args returns the arguments for `go test`, using the given shuffle seed.
+func (o BenchOptions) args(seed int64) []string {
+	args := []string{"test", "-bench=" + cmp.Or(o.Bench, "."), "-benchmem"}
+	if o.Benchtime != "" {
+		args = append(args, "-benchtime="+o.Benchtime)
+	}
+
+	opts := o.TestOptions
+	opts.Run = cmp.Or(opts.Run, "^$")
+	opts.Count = cmp.Or(opts.Count, defaultBenchCount)
+
+	return append(args, opts.args(seed)...)
+}
+
+// BenchResult is the result of running the benchmarks of the repository.
+type BenchResult struct {
+	// Benchmarks are the benchmarks in the order they first ran.
+	Benchmarks []Benchmark
+}
+
+// Benchmark is a benchmark with all its samples.
+type Benchmark struct {
+	// Module is the module of the repository the benchmark belongs to.
+	Module GoModule
+	// Package is the import path of the package of the benchmark.
+	Package string
+	// Name is the name of the benchmark without the GOMAXPROCS suffix, e.g. "BenchmarkFoo/size=10".
+	Name string
+	// Procs is the GOMAXPROCS value the benchmark ran with.
+	Procs int
+	// Samples are the measurements, one per run.
+	Samples []BenchSample
+}
+
+// BenchSample is a single measurement of a benchmark, i.e. one line of `go test -bench` output.
+type BenchSample struct {
+	// Iterations is the number of times the benchmark loop ran.
+	Iterations int
+	// Values are the measured values by unit, e.g. "ns/op", "B/op", "allocs/op"
+	// and custom metrics reported with b.ReportMetric.
+	Values map[string]float64
+}
+
+// NsPerOp returns the nanoseconds per operation.
+func (s BenchSample) NsPerOp() float64 { return s.Values["ns/op"] }
+
+// BytesPerOp returns the bytes allocated per operation.
+func (s BenchSample) BytesPerOp() float64 { return s.Values["B/op"] }
+
+// AllocsPerOp returns the allocations per operation.
+func (s BenchSample) AllocsPerOp() float64 { return s.Values["allocs/op"] }
+
+// Units returns the units measured by all samples of the benchmark, sorted.
+func (b Benchmark) Units() []string {
+	units := map[string]int{}
+	for _, s := range b.Samples {
+		for unit := range s.Values {
+			units[unit]++
+		}
+	}
+
+	common := []string{}
+	for unit, n := range units {
+		if n == len(b.Samples) {
+			common = append(common, unit)
+		}
+	}
+
+	slices.Sort(common)
+
+	return common
+}
+
+// values returns the values of all samples in the unit.
+func (b Benchmark) values(unit string) []float64 {
+	vals := make([]float64, len(b.Samples))
+	for i, s := range b.Samples {
+		vals[i] = s.Values[unit]
+	}
+
+	return vals
+}
+
+// Benchmark returns the benchmark of the package with the name and GOMAXPROCS value.
+func (res *BenchResult) Benchmark(pkg, name string, procs int) (Benchmark, bool) {
+	i := slices.IndexFunc(res.Benchmarks, func(b Benchmark) bool {
+		return b.Package == pkg && b.Name == name && b.Procs == procs
+	})
+	if i < 0 {
+		return Benchmark{}, false
+	}
+
+	return res.Benchmarks[i], true
+}
+
+// Bench runs the benchmarks of every module of the repository with `go test -bench -benchmem`
+// and returns the samples per benchmark. An error is returned if a benchmark fails.
+func (r Repository) Bench(ctx context.Context, opts BenchOptions) (*BenchResult, error) {
+	args := opts.args(opts.shuffleSeed())
+
+	res := &BenchResult{}
+	if err := r.forEachModule(func(m GoModule) error {
+		out, err := r.outputEnvIn(ctx, m.Dir, opts.Env, "go", args...)
+		if err != nil {
+			if execErr := (ghrepo.ExecError{}); errors.As(err, &execErr) && execErr.Out == noTestPackagesMsg {
+				return nil
+			}
+
+			if len(out) > 0 {
+				err = fmt.Errorf("%w\n%s", err, bytes.TrimSpace(out))
+			}
+
+			return err
+		}
+
+		bms, err := parseBenchOutput(m, bytes.NewReader(out))
+		if err != nil {
+			return err
+		}
+
+		res.Benchmarks = append(res.Benchmarks, bms...)
+
+		return nil
+	}); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// parseBenchOutput parses the output of `go test -bench` in the Go benchmark format.
+// Lines that are not benchmark results, e.g. logs of the benchmarks, are ignored.
+func parseBenchOutput(m GoModule, r io.Reader) ([]Benchmark, error) {
+	bms := []Benchmark{}
+	pkg := ""
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			continue
+		}
+
+		name, procs, sample, ok := parseBenchLine(line)
+		if !ok {
+			continue
+		}
+
+		i := slices.IndexFunc(bms, func(b Benchmark) bool { return b.Package == pkg && b.Name == name && b.Procs == procs })
+		if i < 0 {
+			bms = append(bms, Benchmark{Module: m, Package: pkg, Name: name, Procs: procs})
+			i = len(bms) - 1
+		}
+
+		bms[i].Samples = append(bms[i].Samples, sample)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading benchmark output: %w", err)
+	}
+
+	return bms, nil
+}
+
+// parseBenchLine parses a benchmark result line like
+// "BenchmarkFoo-8   1000000   1043 ns/op   16 B/op   1 allocs/op".
+func parseBenchLine(line string) (string, int, BenchSample, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields)%2 != 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return "", 0, BenchSample{}, false
+	}
+
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, BenchSample{}, false
+	}
+
+	sample := BenchSample{Iterations: n, Values: map[string]float64{}}
+	for i := 2; i < len(fields); i += 2 {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return "", 0, BenchSample{}, false
+		}
+
+		sample.Values[fields[i+1]] = v
+	}
+
+	name, procs := fields[0], 1
+	if i := strings.LastIndexByte(name, '-'); i > 0 {
+		if p, err := strconv.Atoi(name[i+1:]); err == nil && p > 0 {
+			name, procs = name[:i], p
+		}
+	}
+
+	return name, procs, sample, true
+}
+
+// BenchDelta is the change of a benchmark in one unit between two runs.
+type BenchDelta struct {
+	// Package is the import path of the package of the benchmark.
+	Package string
+	// Name is the name of the benchmark without the GOMAXPROCS suffix.
+	Name string
+	// Procs is the GOMAXPROCS value the benchmark ran with.
+	Procs int
+	// Unit is the unit of the values, e.g. "ns/op".
+	Unit string
+	// Base and Head are the medians of the samples of the base and the head run.
+	Base, Head float64
+	// Change is the change of the median from base to head, in percent of the base.
+	Change float64
+	// P is the p-value of the Mann-Whitney U test of the samples.
+	P float64
+	// Significant is true if P is below the significance level, i.e. the change is unlikely to be noise.
+	Significant bool
+}
+
+// HigherIsBetter reports whether an increase of the value is an improvement, as for throughputs like "MB/s".
+func (d BenchDelta) HigherIsBetter() bool { return strings.HasSuffix(d.Unit, "/s") }
+
+// Regressed reports whether the benchmark got significantly worse by more than threshold percent.
+func (d BenchDelta) Regressed(threshold float64) bool {
+	if !d.Significant {
+		return false
+	}
+
+	if d.HigherIsBetter() {
+		return -d.Change > threshold
+	}
+
+	return d.Change > threshold
+}
+
+func (d BenchDelta) String() string {
+	change := "~"
+	if d.Significant {
+		change = fmt.Sprintf("%+.2f%%", d.Change)
+	}
+
+	return fmt.Sprintf("%s %s-%d %s: %g → %g (%s, p=%.3f)",
+		d.Package, d.Name, d.Procs, d.Unit, d.Base, d.Head, change, d.P)
+}
+
+// BenchComparison is the comparison of the benchmarks of two revisions.
+type BenchComparison struct {
+	// Base and Head are the revisions compared. An empty Head is the working tree.
+	Base, Head string
+	// BaseResult and HeadResult are the benchmark results of the revisions.
+	BaseResult, HeadResult *BenchResult
+	// Deltas are the changes of the benchmarks that ran in both revisions, per unit.
+	Deltas []BenchDelta
+}
+
+// Regressions returns the deltas that regressed by more than threshold percent.
+func (c *BenchComparison) Regressions(threshold float64) []BenchDelta {
+	return slices.DeleteFunc(slices.Clone(c.Deltas), func(d BenchDelta) bool { return !d.Regressed(threshold) })
+}
+
+// CompareBenchmarks compares the benchmarks that ran in both results in every unit measured in both,
+// in the manner of benchstat: the medians are compared and a change is significant
+// if the Mann-Whitney U test of the samples yields a p-value below alpha, 0.05 if zero.
+func CompareBenchmarks(base, head *BenchResult, alpha float64) []BenchDelta {
+	alpha = cmp.Or(alpha, defaultBenchAlpha)
+
+	deltas := []BenchDelta{}
+	for _, hb := range head.Benchmarks {
+		bb, ok := base.Benchmark(hb.Package, hb.Name, hb.Procs)
+		if !ok {
+			continue
+		}
+
+		for _, unit := range hb.Units() {
+			if !slices.Contains(bb.Units(), unit) {
+				continue
+			}
+
+			x, y := bb.values(unit), hb.values(unit)
+			d := BenchDelta{
+				Package: hb.Package,
+				Name:    hb.Name,
+				Procs:   hb.Procs,
+				Unit:    unit,
+				Base:    median(x),
+				Head:    median(y),
+				P:       mannWhitneyU(x, y),
+			}
+
+			if d.Base != 0 {
+				d.Change = (d.Head - d.Base) / d.Base * 100
+			} else if d.Head != 0 {
+				d.Change = math.Inf(1)
+			}
+
+			d.Significant = d.P < alpha && d.Base != d.Head
+			deltas = append(deltas, d)
+		}
+	}
+
+	return deltas
+}
+
+func median(vals []float64) float64 {
+	if len(vals) == 0 {
+		return 0
+	}
+
+	s := slices.Sorted(slices.Values(vals))
+	if n := len(s); n%2 == 1 {
+		return s[n/2]
+	}
+
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+// mannWhitneyU returns the two-sided p-value of the Mann-Whitney U test of whether the samples
+// come from the same distribution, using the normal approximation with tie and continuity correction.
+func mannWhitneyU(x, y []float64) float64 {
+	n1, n2 := float64(len(x)), float64(len(y))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type obs struct {
+		v     float64
+		fromX bool
+	}
+
+	all := make([]obs, 0, len(x)+len(y))
+	for _, v := range x {
+		all = append(all, obs{v, true})
+	}
+
+	for _, v := range y {
+		all = append(all, obs{v, false})
+	}
+
+	slices.SortFunc(all, func(a, b obs) int { return cmp.Compare(a.v, b.v) })
+
+	// sum of the ranks of x, with ties getting their average rank
+	rankX, ties := 0.0, 0.0
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2 // average of the ranks i+1 to j
+		for k := i; k < j; k++ {
+			if all[k].fromX {
+				rankX += rank
+			}
+		}
+
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u := rankX - n1*(n1+1)/2
+	mu := n1 * n2 / 2
+
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1 // all values are equal
+	}
+
+	z := max(math.Abs(u-mu)-0.5, 0) / sigma
+
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// BenchRevisions runs the benchmarks in the trees of the base and head revisions, e.g. "main" and "HEAD",
+// and compares them with CompareBenchmarks. An empty head benchmarks the working tree.
+// Committed revisions are exported to temporary directories, so the working tree is not touched.
+func (r *Repository) BenchRevisions(ctx context.Context, base, head string, opts BenchOptions, alpha float64) (*BenchComparison, error) {
+	root, err := r.root()
+	if err != nil {
+		return nil, err
+	}
+
+	gr, err := git.PlainOpen(root)
+	if err != nil {
+		return nil, fmt.Errorf("opening git repository: %w", err)
+	}
+
+	c := &BenchComparison{Base: base, Head: head}
+	if c.BaseResult, err = r.benchRevision(ctx, gr, base, opts); err != nil {
+		return c, fmt.Errorf("benchmarking %s: %w", base, err)
+	}
+
+	if c.HeadResult, err = r.benchRevision(ctx, gr, head, opts); err != nil {
+		return c, fmt.Errorf("benchmarking %s: %w", cmp.Or(head, "working tree"), err)
+	}
+
+	c.Deltas = CompareBenchmarks(c.BaseResult, c.HeadResult, alpha)
+
+	return c, nil
+}
+
+// benchRevision runs the benchmarks in the tree of the revision, or in the working tree if rev is empty.
+func (r Repository) benchRevision(ctx context.Context, gr *git.Repository, rev string, opts BenchOptions) (*BenchResult, error) {
+	if rev == "" {
+		return r.Bench(ctx, opts)
+	}
+
+	hash, err := gr.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("resolving revision: %w", err)
+	}
+
+	c, err := gr.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("getting commit: %w", err)
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("getting tree: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "gorepo-bench-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating directory for revision: %w", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	if err := exportTree(tree, dir); err != nil {
+		return nil, err
+	}
+
+	snapshot := Repository{Repository: &ghrepo.Repository{Fs: afero.NewBasePathFs(afero.NewOsFs(), dir)}}
+
+	return snapshot.Bench(ctx, opts)
+}
+
+// exportTree writes the files of the tree into dir.
+func exportTree(tree *object.Tree, dir string) error {
+	return tree.Files().ForEach(func(f *object.File) error {
+		name := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return fmt.Errorf("exporting %s: %w", f.Name, err)
+		}
+
+		contents, err := f.Contents()
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+
+		switch f.Mode {
+		case filemode.Symlink:
+			err = os.Symlink(contents, name)
+		case filemode.Executable:
+			err = os.WriteFile(name, []byte(contents), 0o755)
+		default:
+			err = os.WriteFile(name, []byte(contents), 0o644)
+		}
+
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", f.Name, err)
+		}
+
+		return nil
+	})
+}
+
+// BenchCheckOptions configures CheckBench.
+type BenchCheckOptions struct {
+	// Bench configures how the benchmarks are run.
+	Bench BenchOptions
+	// Base is the revision to compare with. If empty, it is the merge base of HEAD and the default branch.
+	Base string
+	// Head is the revision to check. If empty, it is the working tree.
+	Head string
+	// Threshold is the change in percent a benchmark may get worse by.
+	// Zero fails on any significant regression.
+	Threshold float64
+	// Alpha is the significance level, 0.05 if zero.
+	Alpha float64
+}
+
+// BenchRegression is a benchmark that got significantly worse by more than the threshold.
+type BenchRegression struct{ BenchDelta }
+
+func (r BenchRegression) Error() string {
+	return fmt.Sprintf("benchmark %s %s-%d regressed by %.2f%% in %s (p=%.3f)",
+		r.Package, r.Name, r.Procs, math.Abs(r.Change), r.Unit, r.P)
+}
+
+// CheckBench compares the benchmarks of the head revision with those of the base revision.
+// If any benchmark regressed beyond the threshold, it returns the comparison along with
+// an error joining the BenchRegression errors.
+func (r *Repository) CheckBench(ctx context.Context, opts BenchCheckOptions) (*BenchComparison, error) {
+	base := opts.Base
+	if base == "" {
+		root, err := r.root()
+		if err != nil {
+			return nil, err
+		}
+
+		gr, err := git.PlainOpen(root)
+		if err != nil {
+			return nil, fmt.Errorf("opening git repository: %w", err)
+		}
+
+		c, err := mergeBase(gr)
+		if err != nil {
+			return nil, err
+		}
+
+		base = c.Hash.String()
+	}
+
+	c, err := r.BenchRevisions(ctx, base, opts.Head, opts.Bench, opts.Alpha)
+	if err != nil {
+		return c, err
+	}
+
+	errs := []error{}
+	for _, d := range c.Regressions(opts.Threshold) {
+		errs = append(errs, BenchRegression{d})
+	}
+
+	return c, errors.Join(errs...)
+}
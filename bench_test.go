@@ -0,0 +1,179 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBenchOptions_Args(t *testing.T) {
+	got := strings.Join(BenchOptions{Benchtime: "100x"}.args(0), " ")
+	if want := "test -bench=. -benchmem -benchtime=100x -run=^$ -count=6 ./..."; got != want {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+}
+
+func TestParseBenchOutput(t *testing.T) {
+	const out = `goos: linux
+goarch: amd64
+pkg: example.com/m
+cpu: Some CPU
+BenchmarkA-8   	 1000000	      1043 ns/op	      16 B/op	       1 allocs/op
+BenchmarkA-8   	 1000000	      1050 ns/op	      16 B/op	       1 allocs/op
+BenchmarkB/size=10   	     100	     20000 ns/op	  12.50 MB/s	       3.000 widgets/op
+    bench_test.go:12: some log output
+PASS
+ok  	example.com/m	2.1s
+pkg: example.com/m/sub
+BenchmarkA-8   	 1000	      5 ns/op
+PASS
+`
+	bms, err := parseBenchOutput(GoModule{Dir: "."}, strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bms) != 3 {
+		t.Fatalf("got %d benchmarks: %+v", len(bms), bms)
+	}
+
+	a := bms[0]
+	if a.Package != "example.com/m" || a.Name != "BenchmarkA" || a.Procs != 8 || len(a.Samples) != 2 {
+		t.Errorf("unexpected benchmark %+v", a)
+	}
+
+	if s := a.Samples[1]; s.Iterations != 1000000 || s.NsPerOp() != 1050 || s.BytesPerOp() != 16 || s.AllocsPerOp() != 1 {
+		t.Errorf("unexpected sample %+v", s)
+	}
+
+	b := bms[1]
+	if b.Name != "BenchmarkB/size=10" || b.Procs != 1 || b.Samples[0].Values["widgets/op"] != 3 {
+		t.Errorf("unexpected benchmark %+v", b)
+	}
+
+	if units := strings.Join(b.Units(), " "); units != "MB/s ns/op widgets/op" {
+		t.Errorf("Units() = %q", units)
+	}
+
+	if bms[2].Package != "example.com/m/sub" {
+		t.Errorf("unexpected package %q", bms[2].Package)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	for _, tc := range []struct {
+		x, y []float64
+		want float64
+	}{
+		{[]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}, 0.0122},
+		{[]float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}, 0.6761},
+		{[]float64{1, 1, 1}, []float64{1, 1, 1}, 1},
+		{nil, []float64{1}, 1},
+	} {
+		if got := mannWhitneyU(tc.x, tc.y); math.Abs(got-tc.want) > 0.0001 {
+			t.Errorf("mannWhitneyU(%v, %v) = %.4f, want %.4f", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func benchResult(pkg, name, unit string, vals ...float64) *BenchResult {
+	b := Benchmark{Package: pkg, Name: name, Procs: 1}
+	for _, v := range vals {
+		b.Samples = append(b.Samples, BenchSample{Iterations: 1, Values: map[string]float64{unit: v}})
+	}
+
+	return &BenchResult{Benchmarks: []Benchmark{b}}
+}
+
+func TestCompareBenchmarks(t *testing.T) {
+	base := benchResult("p", "BenchmarkA", "ns/op", 100, 101, 99, 100, 102)
+	slower := benchResult("p", "BenchmarkA", "ns/op", 120, 121, 119, 120, 122)
+
+	deltas := CompareBenchmarks(base, slower, 0)
+	if len(deltas) != 1 {
+		t.Fatalf("got deltas %+v", deltas)
+	}
+
+	d := deltas[0]
+	if !d.Significant || d.Base != 100 || d.Head != 120 || d.Change != 20 {
+		t.Errorf("unexpected delta %+v", d)
+	}
+
+	if !d.Regressed(10) || d.Regressed(25) {
+		t.Errorf("Regressed() wrong for %+v", d)
+	}
+
+	// a higher throughput is an improvement
+	d.Unit = "MB/s"
+	if d.Regressed(0) {
+		t.Error("throughput increase reported as regression")
+	}
+
+	noise := benchResult("p", "BenchmarkA", "ns/op", 100, 103, 98, 101, 99)
+	if d := CompareBenchmarks(base, noise, 0)[0]; d.Significant {
+		t.Errorf("noise reported as significant: %+v", d)
+	}
+
+	if deltas := CompareBenchmarks(base, benchResult("p", "BenchmarkOther", "ns/op", 1), 0); len(deltas) != 0 {
+		t.Errorf("compared different benchmarks: %+v", deltas)
+	}
+}
+
+func TestCheckBench(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+
+	const benchTest = `package m
+
+import "testing"
+
+var sink int
+
+func BenchmarkWork(b *testing.B) {
+	for range b.N {
+		sink = work()
+	}
+}
+`
+
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":        "module example.com/m\n\ngo 1.24\n",
+		"m.go":          "package m\n\nfunc work() int {\n\tn := 0\n\tfor i := range 100 {\n\t\tn += i\n\t}\n\treturn n\n}\n",
+		"m_test.go":     benchTest,
+		"other_test.go": "package m\n\nimport \"testing\"\n\nfunc TestNotRun(t *testing.T) { t.Fatal(\"tests must not run\") }\n",
+	})
+
+	if err := repo.CommitAll("initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestFiles(t, repo, map[string]string{
+		"m.go": "package m\n\nimport \"time\"\n\nfunc work() int {\n\ttime.Sleep(100 * time.Microsecond)\n\treturn 0\n}\n",
+	})
+
+	c, err := repo.CheckBench(context.Background(), BenchCheckOptions{
+		Bench:     BenchOptions{Benchtime: "50x", TestOptions: TestOptions{Count: 5}},
+		Base:      "HEAD",
+		Threshold: 50,
+	})
+
+	regression := BenchRegression{}
+	if !errors.As(err, &regression) {
+		t.Fatalf("expected regression, got %v", err)
+	}
+
+	if !slices.ContainsFunc(c.Regressions(50), func(d BenchDelta) bool {
+		return d.Name == "BenchmarkWork" && d.Unit == "ns/op"
+	}) {
+		t.Errorf("ns/op regression not reported: %v", err)
+	}
+
+	if len(c.BaseResult.Benchmarks) != 1 || len(c.BaseResult.Benchmarks[0].Samples) != 5 {
+		t.Errorf("unexpected base result %+v", c.BaseResult)
+	}
+}
@@ -0,0 +1,485 @@
+package gorepo
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// CoverageBadgeFile is the conventional name of the coverage badge in the repository.
+const CoverageBadgeFile = "coverage.svg"
+
+// WriteCoverProfile writes the coverage of the module in the format of `go test -coverprofile`.
+func WriteCoverProfile(w io.Writer, cov ModuleCoverage) error {
+	mode := cov.Mode
+	if mode == "" {
+		mode = "set"
+	}
+
+	if _, err := fmt.Fprintf(w, "mode: %s\n", mode); err != nil {
+		return err
+	}
+
+	for _, p := range cov.Packages {
+		for _, f := range p.Files {
+			for _, b := range f.Blocks {
+				if _, err := fmt.Fprintf(w, "%s:%d.%d,%d.%d %d %d\n",
+					f.Name, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, b.Count); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteCoverageHTML writes the HTML coverage report of `go tool cover -html` for every module into dir,
+// "coverage.html" for the root module and e.g. "coverage_tools_lint.html" for the module in tools/lint.
+// It returns the paths of the files written.
+func (r *Repository) WriteCoverageHTML(ctx context.Context, res *CoverResult, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating directory for coverage reports: %w", err)
+	}
+
+	tmp, err := os.MkdirTemp("", "gorepo-cover-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating directory for coverage profiles: %w", err)
+	}
+	defer os.RemoveAll(tmp) //nolint:errcheck
+
+	files := []string{}
+	for _, cov := range res.Modules {
+		if len(cov.Packages) == 0 {
+			continue
+		}
+
+		profile := filepath.Join(tmp, coverProfileName(cov.Module))
+		if err := writeOSFile(profile, func(w io.Writer) error { return WriteCoverProfile(w, cov) }); err != nil {
+			return files, err
+		}
+
+		out, err := filepath.Abs(filepath.Join(dir, moduleFileName(cov.Module, "coverage", ".html")))
+		if err != nil {
+			return files, err
+		}
+
+		if _, err := r.execIn(ctx, cov.Module.Dir, "go", "tool", "cover", "-html="+profile, "-o", out); err != nil {
+			return files, ModuleError{Module: cov.Module, Err: err}
+		}
+
+		files = append(files, out)
+	}
+
+	return files, nil
+}
+
+// writeOSFile creates the named file on the local filesystem and writes it with write.
+func writeOSFile(name string, write func(w io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	if err := write(f); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	return f.Close()
+}
+
+// coveredFile is a file of the coverage result with the path used in reports.
+type coveredFile struct {
+	FileCoverage
+	// Path is relative to the repository root for files of the repository, otherwise the name in the profile.
+	Path string
+	// Package is the import path of the package of the file.
+	Package string
+}
+
+// coveredFiles returns the files of all modules, sorted by path.
+func coveredFiles(res *CoverResult) []coveredFile {
+	files := []coveredFile{}
+	for _, m := range res.Modules {
+		for _, p := range m.Packages {
+			for _, f := range p.Files {
+				name, ok := sourceFile(m.Module, f.Name)
+				if !ok {
+					name = f.Name
+				}
+
+				files = append(files, coveredFile{FileCoverage: f, Path: filepath.ToSlash(name), Package: p.Path})
+			}
+		}
+	}
+
+	slices.SortStableFunc(files, func(a, b coveredFile) int { return strings.Compare(a.Path, b.Path) })
+
+	return files
+}
+
+// lineHits returns the execution count per line of the blocks with statements.
+// A line covered by several blocks gets the highest count.
+func lineHits(blocks []ProfileBlock) map[int]int {
+	hits := map[int]int{}
+	for _, b := range blocks {
+		if b.NumStmt == 0 {
+			continue
+		}
+
+		for line := b.StartLine; line <= b.EndLine; line++ {
+			if n, ok := hits[line]; !ok || b.Count > n {
+				hits[line] = b.Count
+			}
+		}
+	}
+
+	return hits
+}
+
+// functionHits returns how often the function was called, i.e. the highest count of the blocks in it.
+func functionHits(fn FunctionCoverage, blocks []ProfileBlock) int {
+	n := 0
+	for _, b := range blocks {
+		if b.StartLine >= fn.StartLine && b.EndLine <= fn.EndLine {
+			n = max(n, b.Count)
+		}
+	}
+
+	return n
+}
+
+// WriteLCOV writes the coverage in the LCOV tracefile format, with the line and function coverage of every file.
+// Files of the repository are named by their path relative to the repository root.
+func WriteLCOV(w io.Writer, res *CoverResult) error {
+	for _, f := range coveredFiles(res) {
+		var sb strings.Builder
+
+		sb.WriteString("TN:\nSF:" + f.Path + "\n")
+
+		fnHit := 0
+		for _, fn := range f.Functions {
+			fmt.Fprintf(&sb, "FN:%d,%s\n", fn.StartLine, fn.Name)
+		}
+
+		for _, fn := range f.Functions {
+			n := functionHits(fn, f.Blocks)
+			if n > 0 {
+				fnHit++
+			}
+
+			fmt.Fprintf(&sb, "FNDA:%d,%s\n", n, fn.Name)
+		}
+
+		fmt.Fprintf(&sb, "FNF:%d\nFNH:%d\n", len(f.Functions), fnHit)
+
+		hits := lineHits(f.Blocks)
+		lineHit := 0
+
+		for _, line := range slices.Sorted(maps.Keys(hits)) {
+			if hits[line] > 0 {
+				lineHit++
+			}
+
+			fmt.Fprintf(&sb, "DA:%d,%d\n", line, hits[line])
+		}
+
+		fmt.Fprintf(&sb, "LF:%d\nLH:%d\nend_of_record\n", len(hits), lineHit)
+
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type coberturaCoverage struct {
+	XMLName         xml.Name           `xml:"coverage"`
+	LineRate        float64            `xml:"line-rate,attr"`
+	BranchRate      float64            `xml:"branch-rate,attr"`
+	LinesCovered    int                `xml:"lines-covered,attr"`
+	LinesValid      int                `xml:"lines-valid,attr"`
+	BranchesCovered int                `xml:"branches-covered,attr"`
+	BranchesValid   int                `xml:"branches-valid,attr"`
+	Complexity      float64            `xml:"complexity,attr"`
+	Version         string             `xml:"version,attr"`
+	Timestamp       int64              `xml:"timestamp,attr"`
+	Sources         []string           `xml:"sources>source"`
+	Packages        []coberturaPackage `xml:"packages>package"`
+}
+
+type coberturaPackage struct {
+	Name       string           `xml:"name,attr"`
+	LineRate   float64          `xml:"line-rate,attr"`
+	BranchRate float64          `xml:"branch-rate,attr"`
+	Complexity float64          `xml:"complexity,attr"`
+	Classes    []coberturaClass `xml:"classes>class"`
+}
+
+type coberturaClass struct {
+	Name       string            `xml:"name,attr"`
+	Filename   string            `xml:"filename,attr"`
+	LineRate   float64           `xml:"line-rate,attr"`
+	BranchRate float64           `xml:"branch-rate,attr"`
+	Complexity float64           `xml:"complexity,attr"`
+	Methods    []coberturaMethod `xml:"methods>method"`
+	Lines      []coberturaLine   `xml:"lines>line"`
+}
+
+type coberturaMethod struct {
+	Name       string          `xml:"name,attr"`
+	Signature  string          `xml:"signature,attr"`
+	LineRate   float64         `xml:"line-rate,attr"`
+	BranchRate float64         `xml:"branch-rate,attr"`
+	Complexity float64         `xml:"complexity,attr"`
+	Lines      []coberturaLine `xml:"lines>line"`
+}
+
+type coberturaLine struct {
+	Number int `xml:"number,attr"`
+	Hits   int `xml:"hits,attr"`
+}
+
+// coberturaLines returns the lines between from and to, inclusive, and the number of them that were hit.
+func coberturaLines(hits map[int]int, from, to int) ([]coberturaLine, int) {
+	lines, covered := []coberturaLine{}, 0
+	for _, n := range slices.Sorted(maps.Keys(hits)) {
+		if n < from || n > to {
+			continue
+		}
+
+		lines = append(lines, coberturaLine{Number: n, Hits: hits[n]})
+		if hits[n] > 0 {
+			covered++
+		}
+	}
+
+	return lines, covered
+}
+
+func lineRate(covered, valid int) float64 {
+	if valid == 0 {
+		return 0
+	}
+
+	return float64(covered) / float64(valid)
+}
+
+// WriteCobertura writes the line coverage in the Cobertura XML format, with a package per Go package,
+// a class per file and a method per function. Paths are relative to the repository root, the only source.
+// Branch coverage is not available from Go coverage profiles and reported as zero.
+func WriteCobertura(w io.Writer, res *CoverResult) error {
+	cov := coberturaCoverage{Timestamp: time.Now().UnixMilli(), Sources: []string{"."}}
+	pkgCounts := map[string]*CoverageCounts{}
+
+	for _, f := range coveredFiles(res) {
+		hits := lineHits(f.Blocks)
+		lines, covered := coberturaLines(hits, 0, math.MaxInt)
+
+		class := coberturaClass{
+			Name:     strings.TrimSuffix(path.Base(f.Path), ".go"),
+			Filename: f.Path,
+			LineRate: lineRate(covered, len(lines)),
+			Methods:  []coberturaMethod{},
+			Lines:    lines,
+		}
+
+		for _, fn := range f.Functions {
+			fnLines, fnCovered := coberturaLines(hits, fn.StartLine, fn.EndLine)
+			class.Methods = append(class.Methods, coberturaMethod{
+				Name:     fn.Name,
+				LineRate: lineRate(fnCovered, len(fnLines)),
+				Lines:    fnLines,
+			})
+		}
+
+		i := slices.IndexFunc(cov.Packages, func(p coberturaPackage) bool { return p.Name == f.Package })
+		if i < 0 {
+			cov.Packages = append(cov.Packages, coberturaPackage{Name: f.Package})
+			pkgCounts[f.Package] = &CoverageCounts{}
+			i = len(cov.Packages) - 1
+		}
+
+		cov.Packages[i].Classes = append(cov.Packages[i].Classes, class)
+		pkgCounts[f.Package].add(CoverageCounts{Statements: len(lines), Covered: covered})
+
+		cov.LinesValid += len(lines)
+		cov.LinesCovered += covered
+	}
+
+	for i, p := range cov.Packages {
+		c := pkgCounts[p.Name]
+		cov.Packages[i].LineRate = lineRate(c.Covered, c.Statements)
+	}
+
+	cov.LineRate = lineRate(cov.LinesCovered, cov.LinesValid)
+
+	if _, err := io.WriteString(w, xml.Header+
+		`<!DOCTYPE coverage SYSTEM "http://cobertura.sourceforge.net/xml/coverage-04.dtd">`+"\n"); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+
+	if err := enc.Encode(cov); err != nil {
+		return fmt.Errorf("encoding Cobertura XML: %w", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// badgeColor returns the color of a coverage badge, from red for low to bright green for high coverage.
+func badgeColor(percent float64) string {
+	switch {
+	case percent >= 90:
+		return "#4c1"
+	case percent >= 80:
+		return "#97ca00"
+	case percent >= 70:
+		return "#a4a61d"
+	case percent >= 60:
+		return "#dfb317"
+	case percent >= 50:
+		return "#fe7d37"
+	default:
+		return "#e05d44"
+	}
+}
+
+// formatPercent formats a coverage percentage with one decimal, e.g. "85.3%".
+func formatPercent(percent float64) string { return strconv.FormatFloat(percent, 'f', 1, 64) + "%" }
+
+// WriteCoverageBadge writes an SVG badge in the style of shields.io showing the coverage percentage.
+func WriteCoverageBadge(w io.Writer, percent float64) error {
+	const labelWidth = 61
+
+	value := formatPercent(percent)
+	valueWidth := 7*len(value) + 10
+	width := labelWidth + valueWidth
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="coverage: %[2]s">
+	<title>coverage: %[2]s</title>
+	<linearGradient id="s" x2="0" y2="100%%">
+		<stop offset="0" stop-color="#bbb" stop-opacity=".1"/>
+		<stop offset="1" stop-opacity=".1"/>
+	</linearGradient>
+	<clipPath id="r">
+		<rect width="%[1]d" height="20" rx="3" fill="#fff"/>
+	</clipPath>
+	<g clip-path="url(#r)">
+		<rect width="%[3]d" height="20" fill="#555"/>
+		<rect x="%[3]d" width="%[4]d" height="20" fill="%[5]s"/>
+		<rect width="%[1]d" height="20" fill="url(#s)"/>
+	</g>
+	<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+		<text x="%[6]d" y="15" fill="#010101" fill-opacity=".3">coverage</text>
+		<text x="%[6]d" y="14">coverage</text>
+		<text x="%[7]d" y="15" fill="#010101" fill-opacity=".3">%[2]s</text>
+		<text x="%[7]d" y="14">%[2]s</text>
+	</g>
+</svg>
+`, width, value, labelWidth, valueWidth, badgeColor(percent), labelWidth/2, labelWidth+valueWidth/2)
+
+	return err
+}
+
+// WriteCoverageBadge writes an SVG coverage badge to the named file in the repository, e.g. CoverageBadgeFile,
+// so that the README can show it with a relative link.
+func (r Repository) WriteCoverageBadge(percent float64, name string) error {
+	f, err := r.Create(name)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	if err := WriteCoverageBadge(f, percent); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	return f.Close()
+}
+
+// readmeFile is the README the coverage badge is kept in sync in.
+const readmeFile = "README.md"
+
+// reShieldsCoverageBadge matches a coverage badge served by shields.io in Markdown.
+var reShieldsCoverageBadge = regexp.MustCompile(`https://img\.shields\.io/badge/coverage-[0-9.]+%25-[a-z]+`)
+
+// shieldsColor returns the shields.io color name matching badgeColor.
+func shieldsColor(percent float64) string {
+	switch {
+	case percent >= 90:
+		return "brightgreen"
+	case percent >= 80:
+		return "green"
+	case percent >= 70:
+		return "yellowgreen"
+	case percent >= 60:
+		return "yellow"
+	case percent >= 50:
+		return "orange"
+	default:
+		return "red"
+	}
+}
+
+// SyncReadmeBadge updates the coverage badge from shields.io in README.md to the percentage
+// or, if there is none, adds one below the title. It reports whether README.md changed.
+// It does nothing if there is no README.md or if it shows a local badge like CoverageBadgeFile.
+func (r Repository) SyncReadmeBadge(percent float64) (bool, error) {
+	data, err := afero.ReadFile(r, readmeFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("reading %s: %w", readmeFile, err)
+	}
+
+	readme := string(data)
+	if strings.Contains(readme, "]("+CoverageBadgeFile+")") {
+		return false, nil
+	}
+
+	url := "https://img.shields.io/badge/coverage-" +
+		strings.TrimSuffix(formatPercent(percent), "%") + "%25-" + shieldsColor(percent)
+
+	updated := reShieldsCoverageBadge.ReplaceAllLiteralString(readme, url)
+	if updated == readme && !reShieldsCoverageBadge.MatchString(readme) {
+		badge := "![coverage](" + url + ")\n"
+
+		if title, rest, ok := strings.Cut(readme, "\n"); ok && strings.HasPrefix(title, "# ") {
+			updated = title + "\n\n" + badge + rest
+		} else {
+			updated = badge + "\n" + readme
+		}
+	}
+
+	if updated == readme {
+		return false, nil
+	}
+
+	if err := r.writeFile(readmeFile, []byte(updated)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,197 @@
+package gorepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func testCoverResult() *CoverResult {
+	return &CoverResult{Modules: []ModuleCoverage{{
+		Module: GoModule{Dir: ".", Path: "example.com/m"},
+		Mode:   "count",
+		Packages: []PackageCoverage{{
+			Path:           "example.com/m",
+			CoverageCounts: CoverageCounts{Statements: 3, Covered: 2},
+			Files: []FileCoverage{{
+				Name:           "example.com/m/m.go",
+				CoverageCounts: CoverageCounts{Statements: 3, Covered: 2},
+				Blocks: []ProfileBlock{
+					{StartLine: 3, StartCol: 14, EndLine: 5, EndCol: 2, NumStmt: 2, Count: 4},
+					{StartLine: 7, StartCol: 14, EndLine: 7, EndCol: 30, NumStmt: 1, Count: 0},
+				},
+				Functions: []FunctionCoverage{
+					{Name: "A", StartLine: 3, EndLine: 5, CoverageCounts: CoverageCounts{Statements: 2, Covered: 2}},
+					{Name: "B", StartLine: 7, EndLine: 7, CoverageCounts: CoverageCounts{Statements: 1}},
+				},
+			}},
+		}},
+	}}}
+}
+
+func TestWriteCoverProfile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCoverProfile(&buf, testCoverResult().Modules[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = "mode: count\nexample.com/m/m.go:3.14,5.2 2 4\nexample.com/m/m.go:7.14,7.30 1 0\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	profiles, err := ParseProfiles(&buf)
+	if err != nil || len(profiles) != 1 || len(profiles[0].Blocks) != 2 {
+		t.Errorf("written profile does not parse: %v, %+v", err, profiles)
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLCOV(&buf, testCoverResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	const want = `TN:
+SF:m.go
+FN:3,A
+FN:7,B
+FNDA:4,A
+FNDA:0,B
+FNF:2
+FNH:1
+DA:3,4
+DA:4,4
+DA:5,4
+DA:7,0
+LF:4
+LH:3
+end_of_record
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteCobertura(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCobertura(&buf, testCoverResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	cov := coberturaCoverage{}
+	if err := xml.Unmarshal(buf.Bytes(), &cov); err != nil {
+		t.Fatal(err)
+	}
+
+	if cov.LinesValid != 4 || cov.LinesCovered != 3 || cov.LineRate != 0.75 {
+		t.Errorf("unexpected totals %+v", cov)
+	}
+
+	if len(cov.Packages) != 1 || len(cov.Packages[0].Classes) != 1 {
+		t.Fatalf("unexpected packages %+v", cov.Packages)
+	}
+
+	class := cov.Packages[0].Classes[0]
+	if class.Filename != "m.go" || class.Name != "m" || len(class.Lines) != 4 || len(class.Methods) != 2 {
+		t.Errorf("unexpected class %+v", class)
+	}
+
+	if m := class.Methods[1]; m.Name != "B" || m.LineRate != 0 || len(m.Lines) != 1 {
+		t.Errorf("unexpected method %+v", m)
+	}
+}
+
+func TestWriteCoverageBadge(t *testing.T) {
+	for percent, color := range map[float64]string{12.3: "#e05d44", 75: "#a4a61d", 95.55: "#4c1"} {
+		var buf bytes.Buffer
+		if err := WriteCoverageBadge(&buf, percent); err != nil {
+			t.Fatal(err)
+		}
+
+		svg := buf.String()
+		if !strings.Contains(svg, `fill="`+color+`"`) || !strings.Contains(svg, ">"+formatPercent(percent)+"<") {
+			t.Errorf("unexpected badge for %v%%:\n%s", percent, svg)
+		}
+
+		if err := xml.Unmarshal(buf.Bytes(), new(struct{})); err != nil {
+			t.Errorf("badge for %v%% is not valid XML: %v", percent, err)
+		}
+	}
+}
+
+func TestSyncReadmeBadge(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if changed, err := repo.SyncReadmeBadge(80); err != nil || changed {
+		t.Fatalf("without README: changed %v, err %v", changed, err)
+	}
+
+	writeTestFiles(t, repo, map[string]string{"README.md": "# Title\n\nText.\n"})
+
+	changed, err := repo.SyncReadmeBadge(80)
+	if err != nil || !changed {
+		t.Fatalf("adding badge: changed %v, err %v", changed, err)
+	}
+
+	const want = "# Title\n\n![coverage](https://img.shields.io/badge/coverage-80.0%25-green)\n\nText.\n"
+	if data, _ := afero.ReadFile(repo, "README.md"); string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	if changed, err := repo.SyncReadmeBadge(80); err != nil || changed {
+		t.Errorf("same coverage: changed %v, err %v", changed, err)
+	}
+
+	if changed, err := repo.SyncReadmeBadge(42.42); err != nil || !changed {
+		t.Errorf("updating badge: changed %v, err %v", changed, err)
+	}
+
+	if data, _ := afero.ReadFile(repo, "README.md"); !strings.Contains(string(data), "coverage-42.4%25-red)") {
+		t.Errorf("badge not updated:\n%s", data)
+	}
+}
+
+func TestWriteCoverageHTML(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":    "module example.com/m\n\ngo 1.24\n",
+		"m.go":      "package m\n\nfunc A() int { return 1 }\n",
+		"m_test.go": "package m\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { A() }\n",
+	})
+
+	ctx := context.Background()
+
+	res, err := repo.GoTestCoverWithOptions(ctx, CoverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+
+	files, err := repo.WriteCoverageHTML(ctx, res, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 1 || filepath.Base(files[0]) != "coverage.html" {
+		t.Fatalf("unexpected files %v", files)
+	}
+
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "func A()") {
+		t.Error("HTML report does not show the source")
+	}
+}
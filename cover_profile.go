@@ -0,0 +1,359 @@
+package gorepo
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// Profile is the coverage profile of a single source file, as written by `go test -coverprofile`.
+type Profile struct {
+	// FileName is the import path of the package followed by the file name, e.g. "example.com/m/pkg/a.go".
+	FileName string
+	// Mode is the coverage mode: "set", "count" or "atomic".
+	Mode string
+	// Blocks are the blocks of the file, sorted by position.
+	Blocks []ProfileBlock
+}
+
+// ProfileBlock is a block of statements in a coverage profile.
+type ProfileBlock struct {
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	// NumStmt is the number of statements in the block.
+	NumStmt int
+	// Count is the number of times the block was executed, or 1 if it was executed in mode "set".
+	Count int
+}
+
+// ParseProfiles parses a coverage profile in the format written by `go test -coverprofile`
+// and returns a profile per file, sorted by file name.
+// Blocks that appear multiple times, e.g. once per test binary with -coverpkg, are merged.
+func ParseProfiles(r io.Reader) ([]*Profile, error) {
+	files := map[string]*Profile{}
+	mode := ""
+
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+
+		if m, ok := strings.CutPrefix(line, "mode: "); ok {
+			if mode != "" && m != mode {
+				return nil, fmt.Errorf("inconsistent coverage modes %q and %q", mode, m)
+			}
+
+			mode = m
+
+			continue
+		}
+
+		if mode == "" {
+			return nil, fmt.Errorf("coverage profile line %q before mode line", line)
+		}
+
+		name, b, err := parseProfileLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		p, ok := files[name]
+		if !ok {
+			p = &Profile{FileName: name, Mode: mode}
+			files[name] = p
+		}
+
+		p.Blocks = append(p.Blocks, b)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("scanning coverage profile: %w", err)
+	}
+
+	profiles := make([]*Profile, 0, len(files))
+	for _, p := range files {
+		if err := p.mergeBlocks(); err != nil {
+			return nil, err
+		}
+
+		profiles = append(profiles, p)
+	}
+
+	slices.SortFunc(profiles, func(a, b *Profile) int { return strings.Compare(a.FileName, b.FileName) })
+
+	return profiles, nil
+}
+
+// parseProfileLine parses a line of the form "name.go:line.column,line.column numberOfStatements count".
+func parseProfileLine(line string) (string, ProfileBlock, error) {
+	b := ProfileBlock{}
+
+	i := strings.LastIndexByte(line, ':')
+	if i < 0 {
+		return "", b, fmt.Errorf("invalid coverage profile line %q", line)
+	}
+
+	var start, end, numStmt, count string
+	if fields := strings.Fields(line[i+1:]); len(fields) == 3 {
+		start, end, _ = strings.Cut(fields[0], ",")
+		numStmt, count = fields[1], fields[2]
+	}
+
+	startLine, startCol, _ := strings.Cut(start, ".")
+	endLine, endCol, _ := strings.Cut(end, ".")
+
+	for _, f := range []struct {
+		dst *int
+		s   string
+	}{
+		{&b.StartLine, startLine},
+		{&b.StartCol, startCol},
+		{&b.EndLine, endLine},
+		{&b.EndCol, endCol},
+		{&b.NumStmt, numStmt},
+		{&b.Count, count},
+	} {
+		n, err := strconv.Atoi(f.s)
+		if err != nil {
+			return "", b, fmt.Errorf("invalid coverage profile line %q", line)
+		}
+
+		*f.dst = n
+	}
+
+	return line[:i], b, nil
+}
+
+// mergeBlocks sorts the blocks and merges those at the same position.
+func (p *Profile) mergeBlocks() error {
+	slices.SortStableFunc(p.Blocks, func(a, b ProfileBlock) int {
+		return cmp.Or(cmp.Compare(a.StartLine, b.StartLine), cmp.Compare(a.StartCol, b.StartCol),
+			cmp.Compare(a.EndLine, b.EndLine), cmp.Compare(a.EndCol, b.EndCol))
+	})
+
+	merged := p.Blocks[:0]
+	for _, b := range p.Blocks {
+		if n := len(merged); n > 0 && merged[n-1].samePosition(b) {
+			last := &merged[n-1]
+			if last.NumStmt != b.NumStmt {
+				return fmt.Errorf("inconsistent number of statements in %s:%d.%d", p.FileName, b.StartLine, b.StartCol)
+			}
+
+			if p.Mode == "set" {
+				last.Count = max(last.Count, min(b.Count, 1))
+			} else {
+				last.Count += b.Count
+			}
+
+			continue
+		}
+
+		merged = append(merged, b)
+	}
+
+	p.Blocks = merged
+
+	return nil
+}
+
+func (b ProfileBlock) samePosition(o ProfileBlock) bool {
+	return b.StartLine == o.StartLine && b.StartCol == o.StartCol && b.EndLine == o.EndLine && b.EndCol == o.EndCol
+}
+
+// CoverageCounts are the number of statements and covered statements of a part of the code.
+type CoverageCounts struct {
+	Statements int
+	Covered    int
+}
+
+// Percent returns the percentage of covered statements, or 0 if there are no statements.
+func (c CoverageCounts) Percent() float64 {
+	if c.Statements == 0 {
+		return 0
+	}
+
+	return float64(c.Covered) / float64(c.Statements) * 100
+}
+
+func (c *CoverageCounts) add(o CoverageCounts) {
+	c.Statements += o.Statements
+	c.Covered += o.Covered
+}
+
+// PackageCoverage is the test coverage of a package.
+type PackageCoverage struct {
+	// Path is the import path of the package.
+	Path string
+	CoverageCounts
+	// Files is the coverage per file, sorted by name.
+	Files []FileCoverage
+}
+
+// FileCoverage is the test coverage of a source file.
+type FileCoverage struct {
+	// Name is the import path of the package followed by the file name, as in the coverage profile.
+	Name string
+	CoverageCounts
+	// Blocks are the blocks of the file with their execution counts.
+	Blocks []ProfileBlock
+	// Functions is the coverage per function, in source order.
+	// It is empty if the source file is not part of the module.
+	Functions []FunctionCoverage
+}
+
+// FunctionCoverage is the test coverage of a function or method.
+type FunctionCoverage struct {
+	// Name is the name of the function, or "Type.Method" for a method.
+	Name string
+	// StartLine and EndLine are the lines of the function in the file.
+	StartLine, EndLine int
+	CoverageCounts
+}
+
+// blockCounts returns the statements of the blocks and those of them that were executed.
+func blockCounts(blocks []ProfileBlock) CoverageCounts {
+	c := CoverageCounts{}
+	for _, b := range blocks {
+		c.Statements += b.NumStmt
+		if b.Count > 0 {
+			c.Covered += b.NumStmt
+		}
+	}
+
+	return c
+}
+
+// coverageTree groups the profiles of the module by package and, for the source files of the module,
+// by function. It returns the packages sorted by import path and the counts of the whole module.
+func (r Repository) coverageTree(m GoModule, profiles []*Profile) ([]PackageCoverage, CoverageCounts, error) {
+	pkgs := []PackageCoverage{}
+	index := map[string]int{}
+	total := CoverageCounts{}
+
+	for _, p := range profiles {
+		fc := FileCoverage{Name: p.FileName, CoverageCounts: blockCounts(p.Blocks), Blocks: p.Blocks}
+
+		if name, ok := sourceFile(m, p.FileName); ok {
+			funcs, err := r.functionCoverage(name, p.Blocks)
+			if err != nil {
+				return nil, total, err
+			}
+
+			fc.Functions = funcs
+		}
+
+		pkgPath := path.Dir(p.FileName)
+
+		i, ok := index[pkgPath]
+		if !ok {
+			i = len(pkgs)
+			index[pkgPath] = i
+			pkgs = append(pkgs, PackageCoverage{Path: pkgPath})
+		}
+
+		pkg := &pkgs[i]
+		pkg.Files = append(pkg.Files, fc)
+		pkg.add(fc.CoverageCounts)
+		total.add(fc.CoverageCounts)
+	}
+
+	slices.SortFunc(pkgs, func(a, b PackageCoverage) int { return strings.Compare(a.Path, b.Path) })
+
+	return pkgs, total, nil
+}
+
+// sourceFile returns the path relative to the repository root of the file named in a coverage profile,
+// e.g. "sub/pkg/a.go" for "example.com/sub/pkg/a.go" in the module example.com/sub in sub.
+// It reports false if the file is not part of the module.
+func sourceFile(m GoModule, name string) (string, bool) {
+	rel, ok := strings.CutPrefix(name, m.Path+"/")
+	if !ok || m.Path == "" {
+		return "", false
+	}
+
+	return filepath.Join(m.Dir, filepath.FromSlash(rel)), true
+}
+
+// functionCoverage attributes the blocks to the functions of the source file, like `go tool cover -func`.
+func (r Repository) functionCoverage(name string, blocks []ProfileBlock) ([]FunctionCoverage, error) {
+	src, err := afero.ReadFile(r, name)
+	if err != nil {
+		return nil, fmt.Errorf("reading source of coverage profile: %w", err)
+	}
+
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("parsing source of coverage profile: %w", err)
+	}
+
+	funcs := []FunctionCoverage{}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+
+		start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+
+		inside := []ProfileBlock{}
+		for _, b := range blocks {
+			if !positionBefore(b.StartLine, b.StartCol, start.Line, start.Column) &&
+				!positionBefore(end.Line, end.Column, b.EndLine, b.EndCol) {
+				inside = append(inside, b)
+			}
+		}
+
+		funcs = append(funcs, FunctionCoverage{
+			Name:           funcName(fn),
+			StartLine:      start.Line,
+			EndLine:        end.Line,
+			CoverageCounts: blockCounts(inside),
+		})
+	}
+
+	return funcs, nil
+}
+
+func positionBefore(line1, col1, line2, col2 int) bool {
+	return line1 < line2 || line1 == line2 && col1 < col2
+}
+
+// funcName returns the name of the function, or "Type.Method" for a method.
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	typ := fn.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+		case *ast.IndexExpr:
+			typ = t.X
+		case *ast.IndexListExpr:
+			typ = t.X
+		case *ast.Ident:
+			return t.Name + "." + fn.Name.Name
+		default:
+			return fn.Name.Name
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package gorepo
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseProfiles(t *testing.T) {
+	const profile = `mode: count
+example.com/m/b.go:3.13,5.2 2 1
+example.com/m/a.go:7.13,9.2 1 0
+example.com/m/a.go:3.13,5.2 2 3
+example.com/m/a.go:3.13,5.2 2 4
+`
+	profiles, err := ParseProfiles(strings.NewReader(profile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(profiles) != 2 {
+		t.Fatalf("got %d profiles, want 2", len(profiles))
+	}
+
+	a := profiles[0]
+	if a.FileName != "example.com/m/a.go" || a.Mode != "count" {
+		t.Errorf("unexpected profile %+v", a)
+	}
+
+	want := []ProfileBlock{
+		{StartLine: 3, StartCol: 13, EndLine: 5, EndCol: 2, NumStmt: 2, Count: 7},
+		{StartLine: 7, StartCol: 13, EndLine: 9, EndCol: 2, NumStmt: 1, Count: 0},
+	}
+
+	if len(a.Blocks) != len(want) {
+		t.Fatalf("got %d blocks, want %d", len(a.Blocks), len(want))
+	}
+
+	for i := range want {
+		if a.Blocks[i] != want[i] {
+			t.Errorf("block %d = %+v, want %+v", i, a.Blocks[i], want[i])
+		}
+	}
+
+	if c := blockCounts(a.Blocks); c != (CoverageCounts{Statements: 3, Covered: 2}) {
+		t.Errorf("blockCounts() = %+v", c)
+	}
+}
+
+func TestParseProfiles_Set(t *testing.T) {
+	profiles, err := ParseProfiles(strings.NewReader("mode: set\na/a.go:1.1,2.2 1 1\na/a.go:1.1,2.2 1 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := profiles[0].Blocks[0].Count; n != 1 {
+		t.Errorf("merged count = %d, want 1", n)
+	}
+}
+
+func TestParseProfiles_Invalid(t *testing.T) {
+	for _, profile := range []string{
+		"a/a.go:1.1,2.2 1 1\n",
+		"mode: set\ninvalid\n",
+		"mode: set\na/a.go:1.1,2.2 1\n",
+		"mode: set\na/a.go:1.x,2.2 1 1\n",
+		"mode: set\nmode: count\n",
+		"mode: set\na/a.go:1.1,2.2 1 1\na/a.go:1.1,2.2 2 1\n",
+	} {
+		if _, err := ParseProfiles(strings.NewReader(profile)); err == nil {
+			t.Errorf("expected error for %q", profile)
+		}
+	}
+}
+
+func TestCoverageTree(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"sub/pkg/a.go": `package pkg
+
+func F() int {
+	return 1
+}
+
+type T[E any] struct{}
+
+func (*T[E]) M(b bool) int {
+	if b {
+		return 1
+	}
+	return 2
+}
+`,
+	})
+
+	profiles, err := ParseProfiles(strings.NewReader(`mode: set
+example.com/sub/pkg/a.go:3.14,5.2 1 1
+example.com/sub/pkg/a.go:9.28,10.7 1 1
+example.com/sub/pkg/a.go:10.7,12.3 1 0
+example.com/sub/pkg/a.go:13.2,13.10 1 1
+example.com/other/b.go:1.1,2.2 4 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs, total, err := repo.coverageTree(GoModule{Dir: "sub", Path: "example.com/sub"}, profiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != (CoverageCounts{Statements: 8, Covered: 3}) {
+		t.Errorf("total = %+v", total)
+	}
+
+	if len(pkgs) != 2 || pkgs[0].Path != "example.com/other" || pkgs[1].Path != "example.com/sub/pkg" {
+		t.Fatalf("unexpected packages %+v", pkgs)
+	}
+
+	if len(pkgs[0].Files[0].Functions) != 0 {
+		t.Error("functions reported for file outside of the module")
+	}
+
+	funcs := pkgs[1].Files[0].Functions
+	want := []FunctionCoverage{
+		{Name: "F", StartLine: 3, EndLine: 5, CoverageCounts: CoverageCounts{Statements: 1, Covered: 1}},
+		{Name: "T.M", StartLine: 9, EndLine: 14, CoverageCounts: CoverageCounts{Statements: 3, Covered: 2}},
+	}
+
+	if len(funcs) != len(want) {
+		t.Fatalf("got %d functions, want %d", len(funcs), len(want))
+	}
+
+	for i := range want {
+		if funcs[i] != want[i] {
+			t.Errorf("function %d = %+v, want %+v", i, funcs[i], want[i])
+		}
+	}
+}
+
+func TestCoverResult_WorstCovered(t *testing.T) {
+	res := &CoverResult{Modules: []ModuleCoverage{
+		{Packages: []PackageCoverage{
+			{Path: "a", CoverageCounts: CoverageCounts{Statements: 10, Covered: 5}},
+			{Path: "b", CoverageCounts: CoverageCounts{Statements: 10, Covered: 9}},
+			{Path: "empty"},
+		}},
+		{Packages: []PackageCoverage{
+			{Path: "c", CoverageCounts: CoverageCounts{Statements: 20, Covered: 10}},
+			{Path: "d", CoverageCounts: CoverageCounts{Statements: 4}},
+		}},
+	}}
+
+	got := []string{}
+	for _, p := range res.WorstCovered(3) {
+		got = append(got, p.Path)
+	}
+
+	if want := "d c a"; strings.Join(got, " ") != want {
+		t.Errorf("WorstCovered(3) = %v, want %v", got, want)
+	}
+
+	if n := len(res.WorstCovered(0)); n != 4 {
+		t.Errorf("WorstCovered(0) returned %d packages, want 4", n)
+	}
+}
+
+func TestGoTestCoverWithOptions_Packages(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":       "module example.com/m\n\ngo 1.24\n",
+		"a/a.go":       "package a\n\nfunc A() int { return 1 }\n\nfunc B() int { return 2 }\n",
+		"a/a_test.go":  "package a\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { A() }\n",
+		"b/b.go":       "package b\n\nfunc B() int { return 2 }\n",
+		"b/b_test.go":  "package b\n\nimport \"testing\"\n\nfunc TestB(t *testing.T) { B() }\n",
+		"c/c.go":       "package c\n\nfunc C() int { return 3 }\n",
+		"c/c_test.go":  "package c\n",
+		"notests/n.go": "package notests\n",
+	})
+
+	res, err := repo.GoTestCoverWithOptions(context.Background(), CoverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgs := res.Modules[0].Packages
+	if len(pkgs) < 3 {
+		t.Fatalf("got packages %+v", pkgs)
+	}
+
+	worst := res.WorstCovered(1)
+	if len(worst) != 1 || worst[0].Path != "example.com/m/c" {
+		t.Errorf("WorstCovered(1) = %+v", worst)
+	}
+
+	for _, p := range pkgs {
+		if p.Path != "example.com/m/a" {
+			continue
+		}
+
+		if p.Percent() != 50 || len(p.Files) != 1 || len(p.Files[0].Functions) != 2 {
+			t.Errorf("unexpected coverage of package a: %+v", p)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package gorepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"math"
+	"slices"
+
+	"github.com/spf13/afero"
+)
+
+// CoverageBaselineFile is the default name of the file in the repository storing the coverage baseline.
+const CoverageBaselineFile = ".coverage.json"
+
+// CoverageBaseline is the coverage that must not be undercut, as stored in the baseline file.
+type CoverageBaseline struct {
+	// Total is the statement coverage of all modules combined, in percent.
+	Total float64 `json:"total"`
+	// Packages is the statement coverage per package import path, in percent.
+	Packages map[string]float64 `json:"packages"`
+}
+
+// CoverageCheckOptions configures CheckCoverage.
+type CoverageCheckOptions struct {
+	// Cover configures how the tests are run.
+	Cover CoverOptions
+	// Baseline is the name of the baseline file in the repository, CoverageBaselineFile if empty.
+	Baseline string
+	// Tolerance is the number of percentage points the total or a package may drop below the baseline.
+	Tolerance float64
+}
+
+// CoverageCheck is the result of CheckCoverage.
+type CoverageCheck struct {
+	// Result is the coverage measured.
+	Result *CoverResult
+	// Baseline is the baseline the coverage was compared with, as it was before the check.
+	Baseline CoverageBaseline
+	// Regressions are the drops in coverage beyond the tolerance.
+	Regressions []CoverageRegression
+	// Updated is true if the baseline was raised and committed.
+	Updated bool
+}
+
+// CoverageRegression is a drop in coverage below the baseline.
+type CoverageRegression struct {
+	// Package is the import path of the package, or empty for the total coverage.
+	Package string
+	// Baseline is the coverage in the baseline, in percent.
+	Baseline float64
+	// Coverage is the coverage measured, in percent.
+	Coverage float64
+}
+
+func (r CoverageRegression) Error() string {
+	name := "total coverage"
+	if r.Package != "" {
+		name = "coverage of " + r.Package
+	}
+
+	return fmt.Sprintf("%s dropped from %.2f%% to %.2f%%", name, r.Baseline, r.Coverage)
+}
+
+// CheckCoverage runs the tests with coverage and compares the total and per-package coverage with the baseline.
+// If the total or any package dropped by more than the tolerance, it returns the check along with
+// an error joining the CoverageRegression errors.
+// Otherwise, if the coverage of the total or any package improved or the baseline does not exist yet,
+// the baseline is raised to the measured coverage and committed, so that coverage can only go up.
+// Packages that no longer exist are removed from the baseline.
+func (r *Repository) CheckCoverage(ctx context.Context, opts CoverageCheckOptions) (*CoverageCheck, error) {
+	name := opts.Baseline
+	if name == "" {
+		name = CoverageBaselineFile
+	}
+
+	baseline, exists, err := r.readCoverageBaseline(name)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.GoTestCoverWithOptions(ctx, opts.Cover)
+	if err != nil {
+		return nil, err
+	}
+
+	check := &CoverageCheck{Result: res, Baseline: baseline}
+	current := coverageBaseline(res)
+
+	check.Regressions = compareCoverage(baseline, current, opts.Tolerance)
+	if len(check.Regressions) > 0 {
+		errs := make([]error, len(check.Regressions))
+		for i, reg := range check.Regressions {
+			errs[i] = reg
+		}
+
+		return check, errors.Join(errs...)
+	}
+
+	raised := raiseBaseline(baseline, current)
+	if exists && raised.Total == baseline.Total && maps.Equal(raised.Packages, baseline.Packages) {
+		return check, nil
+	}
+
+	if err := r.writeCoverageBaseline(name, raised); err != nil {
+		return check, err
+	}
+
+	if err := r.Commit([]string{name}, fmt.Sprintf("test: raise coverage baseline to %.2f%%", raised.Total)); err != nil {
+		return check, err
+	}
+
+	check.Updated = true
+
+	return check, nil
+}
+
+// readCoverageBaseline reads the baseline file. It reports whether the file exists.
+func (r Repository) readCoverageBaseline(name string) (CoverageBaseline, bool, error) {
+	baseline := CoverageBaseline{Packages: map[string]float64{}}
+
+	data, err := afero.ReadFile(r, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return baseline, false, nil
+	} else if err != nil {
+		return baseline, false, fmt.Errorf("reading coverage baseline: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, false, fmt.Errorf("parsing coverage baseline %s: %w", name, err)
+	}
+
+	if baseline.Packages == nil {
+		baseline.Packages = map[string]float64{}
+	}
+
+	return baseline, true, nil
+}
+
+func (r Repository) writeCoverageBaseline(name string, baseline CoverageBaseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding coverage baseline: %w", err)
+	}
+
+	return r.writeFile(name, append(data, '\n'))
+}
+
+// coverageBaseline returns the measured coverage in the form of a baseline,
+// rounded to hundredths of a percent so that the baseline does not change with every run.
+func coverageBaseline(res *CoverResult) CoverageBaseline {
+	b := CoverageBaseline{Total: roundCoverage(res.Coverage), Packages: map[string]float64{}}
+	for _, m := range res.Modules {
+		for _, p := range m.Packages {
+			if p.Statements > 0 {
+				b.Packages[p.Path] = roundCoverage(p.Percent())
+			}
+		}
+	}
+
+	return b
+}
+
+func roundCoverage(percent float64) float64 { return math.Round(percent*100) / 100 }
+
+// compareCoverage returns the drops from the baseline to the current coverage beyond the tolerance,
+// the total first, then the packages sorted by import path.
+func compareCoverage(baseline, current CoverageBaseline, tolerance float64) []CoverageRegression {
+	regressions := []CoverageRegression{}
+	if current.Total < baseline.Total-tolerance {
+		regressions = append(regressions, CoverageRegression{Baseline: baseline.Total, Coverage: current.Total})
+	}
+
+	for _, pkg := range slices.Sorted(maps.Keys(baseline.Packages)) {
+		cov, ok := current.Packages[pkg]
+		if ok && cov < baseline.Packages[pkg]-tolerance {
+			regressions = append(regressions, CoverageRegression{
+				Package:  pkg,
+				Baseline: baseline.Packages[pkg],
+				Coverage: cov,
+			})
+		}
+	}
+
+	return regressions
+}
+
+// raiseBaseline returns the higher of the baseline and the current coverage for the total and every current package.
+// Drops within the tolerance thus do not lower the baseline.
+func raiseBaseline(baseline, current CoverageBaseline) CoverageBaseline {
+	raised := CoverageBaseline{Total: max(baseline.Total, current.Total), Packages: map[string]float64{}}
+	for pkg, cov := range current.Packages {
+		if old, ok := baseline.Packages[pkg]; ok {
+			cov = max(cov, old)
+		}
+
+		raised.Packages[pkg] = cov
+	}
+
+	return raised
+}
@@ -0,0 +1,113 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/spf13/afero"
+)
+
+func TestCompareCoverage(t *testing.T) {
+	baseline := CoverageBaseline{Total: 80, Packages: map[string]float64{"a": 90, "b": 50, "gone": 100}}
+	current := CoverageBaseline{Total: 79.5, Packages: map[string]float64{"a": 88, "b": 49.5, "new": 0}}
+
+	got := compareCoverage(baseline, current, 1)
+	if len(got) != 1 || got[0] != (CoverageRegression{Package: "a", Baseline: 90, Coverage: 88}) {
+		t.Errorf("compareCoverage() = %v", got)
+	}
+
+	if got := compareCoverage(baseline, current, 0); len(got) != 3 || got[0].Package != "" {
+		t.Errorf("compareCoverage() without tolerance = %v", got)
+	}
+}
+
+func TestRaiseBaseline(t *testing.T) {
+	raised := raiseBaseline(
+		CoverageBaseline{Total: 80, Packages: map[string]float64{"a": 90, "b": 50, "gone": 100}},
+		CoverageBaseline{Total: 79.5, Packages: map[string]float64{"a": 89, "b": 60, "new": 10}},
+	)
+
+	if raised.Total != 80 {
+		t.Errorf("Total = %v, want 80", raised.Total)
+	}
+
+	want := map[string]float64{"a": 90, "b": 60, "new": 10}
+	if len(raised.Packages) != len(want) {
+		t.Fatalf("Packages = %v, want %v", raised.Packages, want)
+	}
+
+	for pkg, cov := range want {
+		if raised.Packages[pkg] != cov {
+			t.Errorf("Packages[%q] = %v, want %v", pkg, raised.Packages[pkg], cov)
+		}
+	}
+}
+
+func TestCheckCoverage(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":    "module example.com/m\n\ngo 1.24\n",
+		"m.go":      "package m\n\nfunc A() int { return 1 }\n\nfunc B() int { return 2 }\n",
+		"m_test.go": "package m\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { A() }\n\nfunc TestB(t *testing.T) { B() }\n",
+	})
+
+	ctx := context.Background()
+
+	// the first run creates the baseline
+	check, err := repo.CheckCoverage(ctx, CoverageCheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !check.Updated {
+		t.Error("baseline not created")
+	}
+
+	if status, err := repo.GitStatus(); err != nil {
+		t.Fatal(err)
+	} else if s, ok := status[CoverageBaselineFile]; ok && (s.Worktree != git.Unmodified || s.Staging != git.Unmodified) {
+		t.Errorf("baseline not committed: %+v", s)
+	}
+
+	baseline, exists, err := repo.readCoverageBaseline(CoverageBaselineFile)
+	if err != nil || !exists {
+		t.Fatalf("reading baseline: %v, exists %v", err, exists)
+	}
+
+	if baseline.Total != 100 || baseline.Packages["example.com/m"] != 100 {
+		t.Errorf("unexpected baseline %+v", baseline)
+	}
+
+	// an unchanged run leaves the baseline alone
+	if check, err := repo.CheckCoverage(ctx, CoverageCheckOptions{}); err != nil {
+		t.Fatal(err)
+	} else if check.Updated {
+		t.Error("baseline updated without improvement")
+	}
+
+	// skipping a test drops the coverage
+	check, err = repo.CheckCoverage(ctx, CoverageCheckOptions{
+		Cover:     CoverOptions{TestOptions: TestOptions{Skip: "TestB"}},
+		Tolerance: 10,
+	})
+
+	regression := CoverageRegression{}
+	if !errors.As(err, &regression) {
+		t.Fatalf("expected regression, got %v", err)
+	}
+
+	if len(check.Regressions) != 2 || regression.Baseline != 100 || regression.Coverage != 50 {
+		t.Errorf("unexpected regressions %v", check.Regressions)
+	}
+
+	if data, err := afero.ReadFile(repo, CoverageBaselineFile); err != nil {
+		t.Fatal(err)
+	} else if len(data) == 0 {
+		t.Error("baseline emptied")
+	}
+}
@@ -0,0 +1,359 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/diff"
+	"github.com/spf13/afero"
+)
+
+// DiffCoverageReport is the test coverage of the lines changed relative to the default branch.
+type DiffCoverageReport struct {
+	// Base is the hash of the merge base of HEAD and the default branch the changes are relative to.
+	Base string
+	// Files is the coverage of the changed lines per changed Go file, sorted by name.
+	// Files without changed executable lines are left out.
+	Files []FileDiffCoverage
+}
+
+// FileDiffCoverage is the coverage of the changed lines of a file.
+// Lines that contain no statements, e.g. comments or declarations, are not counted.
+type FileDiffCoverage struct {
+	// Name is the path of the file relative to the repository root.
+	Name string
+	// Covered are the numbers of the changed lines that were executed by the tests.
+	Covered []int
+	// Uncovered are the numbers of the changed lines that were not executed by the tests.
+	Uncovered []int
+}
+
+// Percent returns the percentage of covered changed lines, or 0 if no executable line changed.
+func (f FileDiffCoverage) Percent() float64 {
+	return CoverageCounts{Statements: len(f.Covered) + len(f.Uncovered), Covered: len(f.Covered)}.Percent()
+}
+
+// Percent returns the percentage of covered changed lines in all files, or 0 if no executable line changed.
+func (rep *DiffCoverageReport) Percent() float64 {
+	c := CoverageCounts{}
+	for _, f := range rep.Files {
+		c.add(CoverageCounts{Statements: len(f.Covered) + len(f.Uncovered), Covered: len(f.Covered)})
+	}
+
+	return c.Percent()
+}
+
+// DiffCoverage runs the tests with the options of GoTestCover and returns the coverage of the lines of Go files
+// that changed between the merge base of HEAD and the default branch and the working tree,
+// i.e. the changes of the current branch including uncommitted ones. Test files and vendored files are left out.
+func (r *Repository) DiffCoverage(ctx context.Context) (*DiffCoverageReport, error) {
+	return r.DiffCoverageWithOptions(ctx, DefaultCoverOptions())
+}
+
+// DiffCoverageWithOptions is like DiffCoverage, but runs the tests as configured by opts.
+func (r *Repository) DiffCoverageWithOptions(ctx context.Context, opts CoverOptions) (*DiffCoverageReport, error) {
+	base, changed, err := r.changedLines()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.GoTestCoverWithOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := diffCoverage(res, changed)
+	rep.Base = base.String()
+
+	return rep, nil
+}
+
+// diffCoverage classifies the changed lines per file as covered or uncovered by the blocks of the coverage result.
+func diffCoverage(res *CoverResult, changed map[string][]int) *DiffCoverageReport {
+	blocks := map[string][]ProfileBlock{}
+	for _, m := range res.Modules {
+		for _, p := range m.Packages {
+			for _, f := range p.Files {
+				if name, ok := sourceFile(m.Module, f.Name); ok {
+					blocks[name] = f.Blocks
+				}
+			}
+		}
+	}
+
+	rep := &DiffCoverageReport{Files: []FileDiffCoverage{}}
+	for _, name := range slices.Sorted(maps.Keys(changed)) {
+		fc := FileDiffCoverage{Name: name}
+
+		for _, line := range changed[name] {
+			executable, covered := false, false
+			for _, b := range blocks[name] {
+				if b.StartLine <= line && line <= b.EndLine {
+					executable = true
+					covered = covered || b.Count > 0
+				}
+			}
+
+			switch {
+			case covered:
+				fc.Covered = append(fc.Covered, line)
+			case executable:
+				fc.Uncovered = append(fc.Uncovered, line)
+			}
+		}
+
+		if len(fc.Covered)+len(fc.Uncovered) > 0 {
+			rep.Files = append(rep.Files, fc)
+		}
+	}
+
+	return rep
+}
+
+// changedLines returns the merge base of HEAD and the default branch and,
+// per changed non-test Go file, the numbers of the lines in the working tree that were added or modified since.
+func (r Repository) changedLines() (plumbing.Hash, map[string][]int, error) {
+	root, err := r.root()
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	gr, err := git.PlainOpen(root)
+	if err != nil {
+		return plumbing.ZeroHash, nil, fmt.Errorf("opening git repository: %w", err)
+	}
+
+	base, err := mergeBase(gr)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	baseTree, err := base.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, nil, fmt.Errorf("getting tree of merge base: %w", err)
+	}
+
+	names, err := r.changedFiles(gr, baseTree)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	changed := map[string][]int{}
+	for _, name := range names {
+		old := ""
+		if f, err := baseTree.File(name); err == nil {
+			if old, err = f.Contents(); err != nil {
+				return plumbing.ZeroHash, nil, fmt.Errorf("reading %s at merge base: %w", name, err)
+			}
+		} else if !errors.Is(err, object.ErrFileNotFound) {
+			return plumbing.ZeroHash, nil, fmt.Errorf("looking up %s at merge base: %w", name, err)
+		}
+
+		current, err := afero.ReadFile(r, filepath.FromSlash(name))
+		if err != nil {
+			return plumbing.ZeroHash, nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+
+		if lines := addedLines(old, string(current)); len(lines) > 0 {
+			changed[filepath.FromSlash(name)] = lines
+		}
+	}
+
+	return base.Hash, changed, nil
+}
+
+// changedFiles returns the non-test Go files outside of vendor directories that differ
+// between the base tree and HEAD or between HEAD and the working tree, excluding deleted files.
+func (r Repository) changedFiles(gr *git.Repository, baseTree *object.Tree) ([]string, error) {
+	names, err := r.changedPaths(gr, baseTree)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(names, func(name string) bool {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") ||
+			slices.Contains(strings.Split(path.Dir(name), "/"), "vendor") {
+			return true
+		}
+
+		_, err := r.Stat(filepath.FromSlash(name))
+
+		return err != nil // deleted
+	}), nil
+}
+
+// changedPaths returns the paths of all files that differ between the base tree and HEAD
+// or between HEAD and the working tree, including deleted and renamed files, sorted.
+func (r Repository) changedPaths(gr *git.Repository, baseTree *object.Tree) ([]string, error) {
+	head, err := headCommit(gr)
+	if err != nil {
+		return nil, err
+	}
+
+	headTree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("getting tree of HEAD: %w", err)
+	}
+
+	changes, err := baseTree.Diff(headTree)
+	if err != nil {
+		return nil, fmt.Errorf("diffing merge base and HEAD: %w", err)
+	}
+
+	names := []string{}
+	for _, c := range changes {
+		names = append(names, c.From.Name, c.To.Name)
+	}
+
+	uncommitted, err := r.GetChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	names = append(names, uncommitted...)
+
+	slices.Sort(names)
+
+	return slices.DeleteFunc(slices.Compact(names), func(name string) bool { return name == "" }), nil
+}
+
+// addedLines returns the numbers of the lines of current that were added or modified compared to old.
+func addedLines(old, current string) []int {
+	lines := []int{}
+	line := 1
+
+	diffs := diff.Do(old, current)
+	for i, d := range diffs {
+		n := countLines(d.Text)
+
+		// a deletion has no destination text and an insertion no source text
+		switch single := diffs[i : i+1]; {
+		case diff.Dst(single) == "":
+			continue
+		case diff.Src(single) == "":
+			for j := range n {
+				lines = append(lines, line+j)
+			}
+		}
+
+		line += n
+	}
+
+	return lines
+}
+
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+
+	return n
+}
+
+func headCommit(gr *git.Repository) (*object.Commit, error) {
+	ref, err := gr.Head()
+	if err != nil {
+		return nil, fmt.Errorf("getting HEAD: %w", err)
+	}
+
+	c, err := gr.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("getting HEAD commit: %w", err)
+	}
+
+	return c, nil
+}
+
+// mergeBase returns the best common ancestor of HEAD and the default branch.
+func mergeBase(gr *git.Repository) (*object.Commit, error) {
+	head, err := gr.Head()
+	if err != nil {
+		return nil, fmt.Errorf("getting HEAD: %w", err)
+	}
+
+	c, err := gr.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("getting HEAD commit: %w", err)
+	}
+
+	branch, err := defaultBranch(gr)
+	if err != nil {
+		return nil, err
+	}
+
+	if head.Name() == branch {
+		return c, nil
+	}
+
+	ref, err := branchRef(gr, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := gr.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("getting commit of default branch: %w", err)
+	}
+
+	bases, err := c.MergeBase(def)
+	if err != nil {
+		return nil, fmt.Errorf("finding merge base: %w", err)
+	}
+
+	if len(bases) == 0 {
+		return nil, errors.New("HEAD and the default branch have no common ancestor")
+	}
+
+	return bases[0], nil
+}
+
+// defaultBranch returns the default branch the way ghrepo determines it:
+// the branch origin/HEAD points to, main if there is no commit yet, or else main or master, whichever exists.
+func defaultBranch(gr *git.Repository) (plumbing.ReferenceName, error) {
+	if ref, err := gr.Reference(plumbing.NewRemoteReferenceName("origin", "HEAD"), true); err == nil {
+		if short, ok := strings.CutPrefix(ref.Name().String(), "refs/remotes/origin/"); ok && short != "HEAD" {
+			return plumbing.NewBranchReferenceName(short), nil
+		}
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return "", fmt.Errorf("getting origin/HEAD: %w", err)
+	}
+
+	if _, err := gr.Head(); errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return plumbing.Main, nil
+	} else if err != nil {
+		return "", fmt.Errorf("getting HEAD: %w", err)
+	}
+
+	for _, name := range []plumbing.ReferenceName{plumbing.Main, plumbing.Master} {
+		if _, err := gr.Reference(name, true); err == nil {
+			return name, nil
+		} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return "", fmt.Errorf("getting reference %s: %w", name, err)
+		}
+	}
+
+	return "", errors.New("no default branch found")
+}
+
+// branchRef returns the reference of the local branch or, if there is none, of its remote-tracking branch.
+func branchRef(gr *git.Repository, branch plumbing.ReferenceName) (*plumbing.Reference, error) {
+	for _, name := range []plumbing.ReferenceName{branch, plumbing.NewRemoteReferenceName("origin", branch.Short())} {
+		if ref, err := gr.Reference(name, true); err == nil {
+			return ref, nil
+		} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, fmt.Errorf("getting reference %s: %w", name, err)
+		}
+	}
+
+	return nil, fmt.Errorf("default branch %s not found", branch.Short())
+}
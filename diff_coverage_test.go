@@ -0,0 +1,180 @@
+package gorepo
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+func TestAddedLines(t *testing.T) {
+	for _, tc := range []struct {
+		name, old, current string
+		want               []int
+	}{
+		{"new file", "", "a\nb\n", []int{1, 2}},
+		{"unchanged", "a\nb\n", "a\nb\n", []int{}},
+		{"modified", "a\nb\nc\n", "a\nB\nc\nd", []int{2, 4}},
+		{"deleted", "a\nb\nc\n", "a\nc\n", []int{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := addedLines(tc.old, tc.current); !slices.Equal(got, tc.want) {
+				t.Errorf("addedLines() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiffCoverage(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":        "module example.com/m\n\ngo 1.24\n",
+		"pkg/a.go":      "package pkg\n\nfunc A() int {\n\treturn 1\n}\n",
+		"pkg/a_test.go": "package pkg\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { A() }\n",
+	})
+
+	if err := repo.CommitAll("initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := repo.root()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gr, err := git.PlainOpen(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// on the default branch, HEAD is its own merge base
+	head, err := headCommit(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if base, err := mergeBase(gr); err != nil || base.Hash != head.Hash {
+		t.Fatalf("mergeBase() = %v, %v, want HEAD %s", base, err, head.Hash)
+	}
+
+	wt, err := gr.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a committed change on the branch
+	writeTestFiles(t, repo, map[string]string{
+		"pkg/a.go":      "package pkg\n\nfunc A() int {\n\treturn B()\n}\n\n// B is new.\nfunc B() int {\n\treturn 2\n}\n",
+		"pkg/a_test.go": "package pkg\n\nimport \"testing\"\n\nfunc TestA(t *testing.T) { A() }\n\nfunc TestB(t *testing.T) { B() }\n",
+	})
+
+	if err := repo.CommitAll("add B"); err != nil {
+		t.Fatal(err)
+	}
+
+	// an uncommitted, untested file
+	writeTestFiles(t, repo, map[string]string{
+		"pkg/c.go": "package pkg\n\nfunc C() int {\n\treturn 3\n}\n",
+	})
+
+	rep, err := repo.DiffCoverageWithOptions(context.Background(), CoverOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Files) != 2 {
+		t.Fatalf("got files %+v, want 2", rep.Files)
+	}
+
+	a, c := rep.Files[0], rep.Files[1]
+	if a.Name != filepath.Join("pkg", "a.go") || !slices.Contains(a.Covered, 4) || !slices.Contains(a.Covered, 9) ||
+		len(a.Uncovered) != 0 {
+		t.Errorf("unexpected coverage of a.go: %+v", a)
+	}
+
+	if c.Name != filepath.Join("pkg", "c.go") || len(c.Covered) != 0 || !slices.Contains(c.Uncovered, 4) {
+		t.Errorf("unexpected coverage of c.go: %+v", c)
+	}
+
+	if a.Percent() != 100 || c.Percent() != 0 || rep.Percent() <= 0 || rep.Percent() >= 100 {
+		t.Errorf("Percent() = %v, %v, %v", a.Percent(), c.Percent(), rep.Percent())
+	}
+}
+
+func TestMergeBase_OriginHead(t *testing.T) {
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+
+	root, err := repo.root()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gr, err := git.PlainOpen(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wt, err := gr.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit := func(name string) plumbing.Hash {
+		t.Helper()
+
+		writeTestFiles(t, repo, map[string]string{name: name})
+
+		if err := repo.CommitAll("add " + name); err != nil {
+			t.Fatal(err)
+		}
+
+		head, err := headCommit(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return head.Hash
+	}
+
+	commit("a") // on master
+
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("work"), Create: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// origin/HEAD points to develop, which is ahead of master and only exists as a remote-tracking branch
+	develop := commit("b")
+	for _, ref := range []*plumbing.Reference{
+		plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", "develop"), develop),
+		plumbing.NewSymbolicReference(plumbing.NewRemoteReferenceName("origin", "HEAD"), plumbing.NewRemoteReferenceName("origin", "develop")),
+	} {
+		if err := gr.Storer.SetReference(ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	commit("c")
+
+	if branch, err := defaultBranch(gr); err != nil || branch != plumbing.NewBranchReferenceName("develop") {
+		t.Errorf("defaultBranch() = %v, %v, want develop", branch, err)
+	}
+
+	if base, err := mergeBase(gr); err != nil || base.Hash != develop {
+		t.Errorf("mergeBase() = %v, %v, want develop at %s", base, err, develop)
+	}
+}
@@ -0,0 +1,117 @@
+package gorepo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/MarkRosemaker/ghrepo"
+	"github.com/spf13/afero"
+)
+
+// root returns the absolute path of the repository on the local filesystem.
+func (r Repository) root() (string, error) {
+	bp, ok := r.Fs.(*afero.BasePathFs)
+	if !ok {
+		return "", errors.New("repository filesystem has no base path")
+	}
+
+	return bp.RealPath(".")
+}
+
+// command returns a command that runs in dir, a directory relative to the repository root,
+// with the environment of the current process extended by env, a list of "key=value" pairs.
+func (r Repository) command(ctx context.Context, dir string, env []string, name string, args ...string) (*exec.Cmd, error) {
+	root, err := r.root()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = filepath.Join(root, dir)
+
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+
+	return cmd, nil
+}
+
+// execIn runs a command in dir, a directory relative to the repository root.
+// Like ExecCommand, it returns the combined stdout + stderr and wraps failures in a ghrepo.ExecError.
+func (r Repository) execIn(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	return r.execEnvIn(ctx, dir, nil, name, args...)
+}
+
+// execEnvIn is like execIn, but adds env to the environment of the command.
+func (r Repository) execEnvIn(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error) {
+	cmd, err := r.command(ctx, dir, env, name, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, ghrepo.ExecError{
+			Cmd: strings.Join(append([]string{name}, args...), " "),
+			Out: string(bytes.TrimSpace(out)),
+			Err: err,
+		}
+	}
+
+	return out, nil
+}
+
+// outputIn runs a command in dir, a directory relative to the repository root, and returns its stdout.
+// Unlike execIn, stderr is kept apart so that machine-readable output is not mixed with diagnostics.
+// If the command fails, its stdout is returned together with a ghrepo.ExecError holding stderr,
+// since commands like `go test -json` report their results on stdout even when they fail.
+func (r Repository) outputIn(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	return r.outputEnvIn(ctx, dir, nil, name, args...)
+}
+
+// outputEnvIn is like outputIn, but adds env to the environment of the command.
+func (r Repository) outputEnvIn(ctx context.Context, dir string, env []string, name string, args ...string) ([]byte, error) {
+	cmd, err := r.command(ctx, dir, env, name, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return stdout.Bytes(), ghrepo.ExecError{
+			Cmd: strings.Join(append([]string{name}, args...), " "),
+			Out: string(bytes.TrimSpace(stderr.Bytes())),
+			Err: err,
+		}
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// decodeJSONStream decodes a sequence of concatenated JSON values, as printed by e.g. `go list -json`.
+func decodeJSONStream[T any](data []byte) ([]T, error) {
+	vals := []T{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var v T
+		if err := dec.Decode(&v); errors.Is(err, io.EOF) {
+			return vals, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding JSON: %w", err)
+		}
+
+		vals = append(vals, v)
+	}
+}
@@ -0,0 +1,296 @@
+package gorepo
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// FlakyTestsFile is the default name of the file in the repository recording the known flaky tests.
+const FlakyTestsFile = ".flaky-tests.json"
+
+// defaultReruns is how often a failed test is re-run if FlakyOptions.Reruns is not set.
+const defaultReruns = 3
+
+// FlakyTest is a test that was seen failing and passing without any change, as recorded in the flaky-test file.
+type FlakyTest struct {
+	// Package is the import path of the package of the test.
+	Package string `json:"package"`
+	// Name is the name of the test, e.g. "TestFoo" or "TestFoo/subtest".
+	Name string `json:"name"`
+	// Flakes is how often the test was found to be flaky.
+	Flakes int `json:"flakes"`
+	// LastSeen is when the test was last found to be flaky.
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// RerunResult is the outcome of re-running a failed test in isolation.
+type RerunResult struct {
+	// Test is the failed result of the original run.
+	Test TestResult
+	// Runs is how often the test was re-run.
+	Runs int
+	// Failures is how many of the re-runs failed. A re-run that did not run the test at all counts as failure.
+	Failures int
+}
+
+// Flaky reports whether the test passed at least once when re-run, i.e. it is flaky rather than consistently failing.
+func (r RerunResult) Flaky() bool { return r.Failures < r.Runs }
+
+// FlakyOptions configures TestFlaky.
+type FlakyOptions struct {
+	// Test configures how the tests are run.
+	Test TestOptions
+	// Reruns is how often every failed test is re-run in isolation, 3 if zero.
+	Reruns int
+	// Record is the name of the flaky-test file in the repository, FlakyTestsFile if empty.
+	Record string
+	// Quarantine runs the known flaky tests of the record in quarantine:
+	// they are run and reported, but their failures do not fail the run.
+	Quarantine bool
+	// Commit commits the flaky-test file if newly found flaky tests were recorded.
+	Commit bool
+}
+
+// FlakyReport is the result of TestFlaky.
+type FlakyReport struct {
+	// Report is the result of the original run.
+	Report *TestReport
+	// Reruns are the results of re-running the failed tests, in the order they failed.
+	Reruns []RerunResult
+	// Quarantined are the results of the known flaky tests that were run in quarantine.
+	Quarantined []TestResult
+	// Updated is true if flaky tests were recorded in the flaky-test file.
+	Updated bool
+}
+
+// TestFailure is a failure of a test run that is not due to a flaky or quarantined test.
+type TestFailure struct {
+	// Package is the import path of the failed package.
+	Package string
+	// Test is the name of the consistently failing test, or empty if the package failed without a failed test,
+	// e.g. because it could not be built or panicked in TestMain.
+	Test string
+}
+
+func (f TestFailure) Error() string {
+	if f.Test == "" {
+		return fmt.Sprintf("package %s failed", f.Package)
+	}
+
+	return fmt.Sprintf("test %s in %s failed consistently", f.Test, f.Package)
+}
+
+// Failed reports whether the run failed for other reasons than flaky or quarantined tests: a package could not be built,
+// a test failed consistently or a package failed without a failed test, e.g. due to a panic in TestMain.
+func (rep *FlakyReport) Failed() bool { return len(rep.failures()) > 0 }
+
+// failures returns the TestFailures of the run.
+func (rep *FlakyReport) failures() []error {
+	failures := []error{}
+	for _, p := range rep.Report.FailedPackages() {
+		failed := failedLeafTests(p.Tests)
+		if p.BuildFailed || len(failed) == 0 {
+			failures = append(failures, TestFailure{Package: p.Package})
+			continue
+		}
+
+		for _, t := range failed {
+			if rep.quarantined(t) || slices.ContainsFunc(rep.Reruns, func(rr RerunResult) bool {
+				return rr.Test.Package == t.Package && rr.Test.Name == t.Name && rr.Flaky()
+			}) {
+				continue
+			}
+
+			failures = append(failures, TestFailure{Package: p.Package, Test: t.Name})
+		}
+	}
+
+	return failures
+}
+
+// quarantined reports whether the test is one of the quarantined tests or one of their subtests.
+func (rep *FlakyReport) quarantined(t TestResult) bool {
+	return slices.ContainsFunc(rep.Quarantined, func(q TestResult) bool {
+		return q.Package == t.Package && (q.Name == t.Name || strings.HasPrefix(t.Name, q.Name+"/"))
+	})
+}
+
+// Flaky returns the results of the failed tests that turned out to be flaky.
+func (rep *FlakyReport) Flaky() []RerunResult {
+	return slices.DeleteFunc(slices.Clone(rep.Reruns), func(rr RerunResult) bool { return !rr.Flaky() })
+}
+
+// TestFlaky runs the tests like Test, re-runs every failed test in isolation and classifies it
+// as consistently failing or flaky. Flaky tests are added to the flaky-test file in the repository.
+// If the run failed for other reasons than flaky or quarantined tests, it returns the report along with
+// an error joining the TestFailure errors.
+func (r *Repository) TestFlaky(ctx context.Context, opts FlakyOptions) (*FlakyReport, error) {
+	name := cmp.Or(opts.Record, FlakyTestsFile)
+
+	known, err := r.readFlakyTests(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &FlakyReport{}
+
+	if rep.Report, err = r.Test(ctx, opts.Test); err != nil {
+		return rep, err
+	}
+
+	if opts.Quarantine {
+		for _, t := range known {
+			if res, ok := rep.Report.Test(t.Package, t.Name); ok {
+				rep.Quarantined = append(rep.Quarantined, res)
+			}
+		}
+	}
+
+	if rep.Reruns, err = r.RerunFailedTests(ctx, rep.Report, opts.Test, cmp.Or(opts.Reruns, defaultReruns)); err != nil {
+		return rep, err
+	}
+
+	flaky := rep.Flaky()
+	if len(flaky) == 0 {
+		return rep, errors.Join(rep.failures()...)
+	}
+
+	if err := r.writeFlakyTests(name, recordFlaky(known, flaky, time.Now().UTC())); err != nil {
+		return rep, err
+	}
+
+	rep.Updated = true
+
+	if opts.Commit {
+		if err := r.Commit([]string{name}, fmt.Sprintf("test: record %d flaky tests", len(flaky))); err != nil {
+			return rep, err
+		}
+	}
+
+	return rep, errors.Join(rep.failures()...)
+}
+
+// RerunFailedTests re-runs every failed test of the report n times in isolation, i.e. on its own with -count=1
+// and without shuffling, using the other options given. Tests whose subtests failed are not re-run themselves,
+// only the failed subtests.
+func (r Repository) RerunFailedTests(ctx context.Context, report *TestReport, opts TestOptions, n int) ([]RerunResult, error) {
+	results := []RerunResult{}
+	for _, p := range report.Packages {
+		for _, t := range failedLeafTests(p.Tests) {
+			rr := RerunResult{Test: t, Runs: n}
+
+			rerunOpts := opts
+			rerunOpts.Packages = []string{p.Package}
+			rerunOpts.Run = testPattern(t.Name)
+			rerunOpts.Skip = ""
+			rerunOpts.Count = 1
+			rerunOpts.Shuffle = false
+			rerunOpts.Seed = 0
+
+			for range n {
+				rerun, err := r.testModule(ctx, p.Module, rerunOpts, 0)
+				if err != nil {
+					return results, ModuleError{Module: p.Module, Err: err}
+				}
+
+				if res, ok := rerun.Test(p.Package, t.Name); !ok || res.Status == TestFail {
+					rr.Failures++
+				}
+			}
+
+			results = append(results, rr)
+		}
+	}
+
+	return results, nil
+}
+
+// failedLeafTests returns the failed tests that have no failed subtests.
+func failedLeafTests(tests []TestResult) []TestResult {
+	failed := []TestResult{}
+	for _, t := range tests {
+		if t.Status != TestFail || slices.ContainsFunc(tests, func(sub TestResult) bool {
+			return sub.Status == TestFail && strings.HasPrefix(sub.Name, t.Name+"/")
+		}) {
+			continue
+		}
+
+		failed = append(failed, t)
+	}
+
+	return failed
+}
+
+// testPattern returns the -run pattern matching exactly the named test or subtest.
+func testPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// recordFlaky returns the known flaky tests updated with the newly found ones, sorted by package and name.
+func recordFlaky(known []FlakyTest, flaky []RerunResult, now time.Time) []FlakyTest {
+	record := slices.Clone(known)
+	for _, rr := range flaky {
+		i := slices.IndexFunc(record, func(t FlakyTest) bool {
+			return t.Package == rr.Test.Package && t.Name == rr.Test.Name
+		})
+		if i < 0 {
+			record = append(record, FlakyTest{Package: rr.Test.Package, Name: rr.Test.Name})
+			i = len(record) - 1
+		}
+
+		record[i].Flakes++
+		record[i].LastSeen = now
+	}
+
+	slices.SortFunc(record, func(a, b FlakyTest) int {
+		return cmp.Or(strings.Compare(a.Package, b.Package), strings.Compare(a.Name, b.Name))
+	})
+
+	return record
+}
+
+// readFlakyTests reads the flaky-test file. A missing file records no flaky tests.
+func (r Repository) readFlakyTests(name string) ([]FlakyTest, error) {
+	data, err := afero.ReadFile(r, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []FlakyTest{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading flaky tests: %w", err)
+	}
+
+	tests := []FlakyTest{}
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return nil, fmt.Errorf("parsing flaky tests %s: %w", name, err)
+	}
+
+	return tests, nil
+}
+
+func (r Repository) writeFlakyTests(name string, tests []FlakyTest) error {
+	data, err := json.MarshalIndent(tests, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding flaky tests: %w", err)
+	}
+
+	return r.writeFile(name, append(data, '\n'))
+}
+
+// FlakyTests returns the flaky tests recorded in the flaky-test file of the given name, FlakyTestsFile if empty.
+func (r Repository) FlakyTests(name string) ([]FlakyTest, error) {
+	return r.readFlakyTests(cmp.Or(name, FlakyTestsFile))
+}
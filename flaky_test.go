@@ -0,0 +1,183 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFailedLeafTests(t *testing.T) {
+	got := failedLeafTests([]TestResult{
+		{Name: "TestA", Status: TestFail},
+		{Name: "TestA/good", Status: TestPass},
+		{Name: "TestA/bad", Status: TestFail},
+		{Name: "TestB", Status: TestFail},
+		{Name: "TestC", Status: TestPass},
+	})
+
+	if len(got) != 2 || got[0].Name != "TestA/bad" || got[1].Name != "TestB" {
+		t.Errorf("failedLeafTests() = %+v", got)
+	}
+}
+
+func TestTestPattern(t *testing.T) {
+	if got, want := testPattern("TestA/case_1.5"), `^TestA$/^case_1\.5$`; got != want {
+		t.Errorf("testPattern() = %q, want %q", got, want)
+	}
+}
+
+func TestFlakyReport_Failed(t *testing.T) {
+	rep := &FlakyReport{
+		Report: &TestReport{Packages: []PackageResult{
+			{Package: "a", Status: TestFail, Tests: []TestResult{
+				{Package: "a", Name: "TestQ", Status: TestFail},
+				{Package: "a", Name: "TestQ/sub", Status: TestFail},
+				{Package: "a", Name: "TestF", Status: TestFail},
+			}},
+			{Package: "b", Status: TestFail, Tests: []TestResult{
+				{Package: "b", Name: "TestQ", Status: TestFail},
+			}},
+			{Package: "c", Status: TestFail, BuildFailed: true},
+		}},
+		Reruns:      []RerunResult{{Test: TestResult{Package: "a", Name: "TestF"}, Runs: 2, Failures: 1}},
+		Quarantined: []TestResult{{Package: "a", Name: "TestQ", Status: TestFail}},
+	}
+
+	// the quarantine is scoped to the package of the test
+	got := rep.failures()
+	want := []error{TestFailure{Package: "b", Test: "TestQ"}, TestFailure{Package: "c"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("failures() = %v, want %v", got, want)
+	}
+
+	rep.Report.Packages = rep.Report.Packages[:1]
+	if rep.Failed() {
+		t.Errorf("failed with only quarantined and flaky tests: %v", rep.failures())
+	}
+}
+
+func TestRecordFlaky(t *testing.T) {
+	old, now := time.Unix(0, 0).UTC(), time.Unix(100, 0).UTC()
+
+	got := recordFlaky(
+		[]FlakyTest{{Package: "b", Name: "TestB", Flakes: 2, LastSeen: old}, {Package: "a", Name: "TestA", Flakes: 1, LastSeen: old}},
+		[]RerunResult{{Test: TestResult{Package: "b", Name: "TestB"}}, {Test: TestResult{Package: "a", Name: "TestC"}}},
+		now,
+	)
+
+	want := []FlakyTest{
+		{Package: "a", Name: "TestA", Flakes: 1, LastSeen: old},
+		{Package: "a", Name: "TestC", Flakes: 1, LastSeen: now},
+		{Package: "b", Name: "TestB", Flakes: 3, LastSeen: now},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("recordFlaky() = %+v", got)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("test %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTestFlaky(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"m_test.go": `package m
+
+import (
+	"os"
+	"testing"
+)
+
+// TestFlaky fails the first time only.
+func TestFlaky(t *testing.T) {
+	marker := os.Getenv("FLAKY_MARKER")
+	if _, err := os.Stat(marker); err != nil {
+		os.WriteFile(marker, nil, 0o644)
+		t.Fatal("flaked")
+	}
+}
+
+func TestBroken(t *testing.T) {
+	t.Run("sub", func(t *testing.T) { t.Fatal("broken") })
+}
+
+func TestOK(t *testing.T) {}
+`,
+	})
+
+	ctx := context.Background()
+	marker := filepath.Join(t.TempDir(), "marker")
+	opts := FlakyOptions{
+		Test:   TestOptions{Env: []string{"FLAKY_MARKER=" + marker}},
+		Reruns: 2,
+	}
+
+	rep, err := repo.TestFlaky(ctx, opts)
+	if failure := (TestFailure{}); !errors.As(err, &failure) || failure.Test != "TestBroken/sub" {
+		t.Fatalf("got error %v, want failure of TestBroken/sub", err)
+	}
+
+	if !rep.Failed() {
+		t.Error("consistently failing test not reported")
+	}
+
+	if len(rep.Reruns) != 2 {
+		t.Fatalf("reruns = %+v", rep.Reruns)
+	}
+
+	for _, rr := range rep.Reruns {
+		switch rr.Test.Name {
+		case "TestBroken/sub":
+			if rr.Flaky() || rr.Failures != 2 {
+				t.Errorf("broken test classified as flaky: %+v", rr)
+			}
+		case "TestFlaky":
+			if !rr.Flaky() || rr.Failures != 0 {
+				t.Errorf("flaky test not classified as flaky: %+v", rr)
+			}
+		default:
+			t.Errorf("unexpected rerun of %s", rr.Test.Name)
+		}
+	}
+
+	if !rep.Updated {
+		t.Error("flaky test not recorded")
+	}
+
+	known, err := repo.FlakyTests("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(known) != 1 || known[0].Package != "example.com/m" || known[0].Name != "TestFlaky" || known[0].Flakes != 1 {
+		t.Errorf("recorded %+v", known)
+	}
+
+	// the quarantined test still runs and is reported, but its failure does not fail the run
+	if err := os.Remove(marker); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Quarantine = true
+	opts.Test.Skip = "TestBroken"
+
+	rep, err = repo.TestFlaky(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Failed() || len(rep.Quarantined) != 1 || rep.Quarantined[0].Name != "TestFlaky" || rep.Quarantined[0].Status != TestFail {
+		t.Errorf("unexpected report %+v", rep)
+	}
+}
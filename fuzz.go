@@ -0,0 +1,370 @@
+package gorepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/MarkRosemaker/ghrepo"
+	"github.com/spf13/afero"
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultFuzzTime is how long every fuzz target runs if FuzzOptions.FuzzTime is not set.
+const defaultFuzzTime = 10 * time.Second
+
+// fuzzCorpusHeader is the first line of every file of a fuzz corpus.
+const fuzzCorpusHeader = "go test fuzz v1"
+
+// FuzzTarget is a fuzz test, i.e. a function FuzzXxx(*testing.F) in a test file.
+type FuzzTarget struct {
+	// Module is the module of the repository the fuzz test belongs to.
+	Module GoModule
+	// Package is the import path of the package of the fuzz test.
+	Package string
+	// Dir is the directory of the package relative to the repository root.
+	Dir string
+	// Name is the name of the fuzz test, e.g. "FuzzParse".
+	Name string
+}
+
+// corpusDir returns the directory the go command writes failing inputs of the target to,
+// relative to the repository root.
+func (t FuzzTarget) corpusDir() string { return filepath.Join(t.Dir, "testdata", "fuzz", t.Name) }
+
+// FuzzTargets returns the fuzz tests of all modules of the repository, sorted by package and name.
+func (r Repository) FuzzTargets() ([]FuzzTarget, error) {
+	targets := []FuzzTarget{}
+	if err := r.forEachModule(func(m GoModule) error {
+		pkgs, err := r.parsePackages(m.Dir)
+		if err != nil {
+			return err
+		}
+
+		for _, pkg := range pkgs {
+			for _, file := range pkg.files {
+				name := pkg.fset.File(file.Pos()).Name()
+				if !strings.HasSuffix(name, "_test.go") {
+					continue
+				}
+
+				dir := filepath.Dir(name)
+
+				rel, err := filepath.Rel(m.Dir, dir)
+				if err != nil {
+					return err
+				}
+
+				for _, fn := range fuzzFuncs(file) {
+					targets = append(targets, FuzzTarget{
+						Module:  m,
+						Package: path.Join(m.Path, filepath.ToSlash(rel)),
+						Dir:     dir,
+						Name:    fn,
+					})
+				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(targets, func(a, b FuzzTarget) int {
+		if c := strings.Compare(a.Package, b.Package); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return targets, nil
+}
+
+// fuzzFuncs returns the names of the fuzz tests declared in the test file.
+func fuzzFuncs(file *ast.File) []string {
+	testing := ""
+	for _, imp := range file.Imports {
+		if p, err := strconv.Unquote(imp.Path.Value); err != nil || p != "testing" {
+			continue
+		}
+
+		testing = "testing"
+		if imp.Name != nil {
+			testing = imp.Name.Name
+		}
+	}
+
+	if testing == "" || testing == "_" {
+		return nil
+	}
+
+	names := []string{}
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !isFuzzName(fn.Name.Name) ||
+			fn.Type.Params == nil || len(fn.Type.Params.List) != 1 || len(fn.Type.Params.List[0].Names) > 1 {
+			continue
+		}
+
+		star, ok := fn.Type.Params.List[0].Type.(*ast.StarExpr)
+		if !ok {
+			continue
+		}
+
+		if sel, ok := star.X.(*ast.SelectorExpr); ok && sel.Sel.Name == "F" {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == testing {
+				names = append(names, fn.Name.Name)
+			}
+		}
+	}
+
+	return names
+}
+
+// isFuzzName reports whether the name is one the go command treats as fuzz test: "Fuzz"
+// followed by nothing or by a character that is not a lower-case letter.
+func isFuzzName(name string) bool {
+	rest, ok := strings.CutPrefix(name, "Fuzz")
+	if !ok {
+		return false
+	}
+
+	if rest == "" {
+		return true
+	}
+
+	r, _ := utf8.DecodeRuneInString(rest)
+
+	return !unicode.IsLower(r)
+}
+
+// FuzzOptions configures Fuzz.
+type FuzzOptions struct {
+	// Targets are the names of the fuzz tests to run, e.g. "FuzzParse". All are run if empty.
+	Targets []string
+	// FuzzTime is how long every fuzz test runs, 10s if zero.
+	FuzzTime time.Duration
+	// Parallel is the number of fuzz tests that run at the same time. Zero or one runs them sequentially.
+	Parallel int
+	// Workers is the number of fuzzing processes per fuzz test, see the -parallel flag of `go test`.
+	// Zero keeps the default of GOMAXPROCS.
+	Workers int
+	// Tags are the build tags to consider satisfied.
+	Tags []string
+	// Env are additional environment variables, as "key=value" pairs.
+	Env []string
+	// Commit commits the new failing inputs, so that they are run as regression tests by `go test` from then on.
+	Commit bool
+}
+
+// FuzzRun is the outcome of fuzzing a single target.
+type FuzzRun struct {
+	// Target is the fuzz test.
+	Target FuzzTarget
+	// Elapsed is how long the fuzz test ran.
+	Elapsed time.Duration
+	// Findings are the failing inputs found.
+	Findings []FuzzFinding
+	// Output is the output of `go test`, including the failure message if there were findings.
+	Output []string
+}
+
+// FuzzFinding is a failing input found by fuzzing.
+type FuzzFinding struct {
+	// Target is the fuzz test that failed.
+	Target FuzzTarget
+	// File is the corpus file of the input relative to the repository root,
+	// e.g. "pkg/testdata/fuzz/FuzzParse/582528ddfad69eb5".
+	File string
+	// Input is the content of the corpus file, the reproducer of the failure.
+	Input []byte
+	// Values are the arguments of the input in the corpus file format, e.g. `string("\x00")`, one per argument.
+	Values []string
+}
+
+// Fuzz runs the fuzz tests of the repository, each with `go test -fuzz` for the configured time.
+// Failing inputs the go command writes to the testdata/fuzz directory of the package are collected
+// as findings and, if configured, committed. A fuzz test failing does not cause an error,
+// but a fuzz test that fails without a new failing input, e.g. because it does not compile, does.
+func (r *Repository) Fuzz(ctx context.Context, opts FuzzOptions) ([]FuzzRun, error) {
+	targets, err := r.FuzzTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(opts.Targets) > 0 {
+		targets = slices.DeleteFunc(targets, func(t FuzzTarget) bool { return !slices.Contains(opts.Targets, t.Name) })
+	}
+
+	runs := make([]FuzzRun, len(targets))
+	errs := make([]error, len(targets))
+
+	eg := errgroup.Group{}
+	eg.SetLimit(max(opts.Parallel, 1))
+
+	for i, t := range targets {
+		eg.Go(func() error {
+			runs[i], errs[i] = r.fuzz(ctx, t, opts)
+			return nil
+		})
+	}
+
+	_ = eg.Wait() // errors are collected per target
+
+	if err := errors.Join(errs...); err != nil {
+		return runs, err
+	}
+
+	if !opts.Commit {
+		return runs, nil
+	}
+
+	files, names := []string{}, []string{}
+	for _, run := range runs {
+		for _, f := range run.Findings {
+			files = append(files, filepath.ToSlash(f.File))
+		}
+
+		if len(run.Findings) > 0 {
+			names = append(names, run.Target.Name)
+		}
+	}
+
+	if len(files) == 0 {
+		return runs, nil
+	}
+
+	if err := r.Commit(files, "test: add failing fuzz inputs of "+strings.Join(names, ", ")); err != nil {
+		return runs, err
+	}
+
+	return runs, nil
+}
+
+// fuzz runs a single fuzz test and collects the failing inputs it added to the corpus.
+func (r Repository) fuzz(ctx context.Context, t FuzzTarget, opts FuzzOptions) (FuzzRun, error) {
+	run := FuzzRun{Target: t}
+
+	before, err := r.corpusFiles(t.corpusDir())
+	if err != nil {
+		return run, err
+	}
+
+	fuzzTime := opts.FuzzTime
+	if fuzzTime <= 0 {
+		fuzzTime = defaultFuzzTime
+	}
+
+	pkgDir, err := filepath.Rel(t.Module.Dir, t.Dir)
+	if err != nil {
+		return run, err
+	}
+
+	args := []string{
+		"test", "-run=^$", "-fuzz=^" + t.Name + "$",
+		"-fuzztime=" + fuzzTime.String(),
+		// the fuzz time must not count towards the default timeout of 10m
+		"-timeout=" + (fuzzTime + 10*time.Minute).String(),
+	}
+
+	if opts.Workers > 0 {
+		args = append(args, "-parallel="+strconv.Itoa(opts.Workers))
+	}
+
+	if len(opts.Tags) > 0 {
+		args = append(args, "-tags="+strings.Join(opts.Tags, ","))
+	}
+
+	args = append(args, "./"+filepath.ToSlash(pkgDir))
+
+	start := time.Now()
+	out, runErr := r.execEnvIn(ctx, t.Module.Dir, opts.Env, "go", args...)
+	run.Elapsed = time.Since(start)
+
+	after, err := r.corpusFiles(t.corpusDir())
+	if err != nil {
+		return run, err
+	}
+
+	for _, name := range after {
+		if slices.Contains(before, name) {
+			continue
+		}
+
+		finding, err := r.fuzzFinding(t, name)
+		if err != nil {
+			return run, err
+		}
+
+		run.Findings = append(run.Findings, finding)
+	}
+
+	if execErr := (ghrepo.ExecError{}); errors.As(runErr, &execErr) {
+		out = []byte(execErr.Out)
+	}
+
+	run.Output = strings.Split(strings.TrimSpace(string(out)), "\n")
+
+	if runErr != nil && len(run.Findings) == 0 {
+		return run, ModuleError{Module: t.Module, Err: fmt.Errorf("fuzzing %s.%s: %w", t.Package, t.Name, runErr)}
+	}
+
+	return run, nil
+}
+
+// corpusFiles returns the paths of the files in the corpus directory relative to the repository root.
+func (r Repository) corpusFiles(dir string) ([]string, error) {
+	entries, err := afero.ReadDir(r, dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading fuzz corpus: %w", err)
+	}
+
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+
+	return files, nil
+}
+
+// fuzzFinding reads the failing input from the corpus file.
+func (r Repository) fuzzFinding(t FuzzTarget, name string) (FuzzFinding, error) {
+	data, err := afero.ReadFile(r, name)
+	if err != nil {
+		return FuzzFinding{}, fmt.Errorf("reading fuzz input: %w", err)
+	}
+
+	return FuzzFinding{Target: t, File: name, Input: data, Values: corpusValues(data)}, nil
+}
+
+// corpusValues returns the argument lines of a corpus file, without the header.
+func corpusValues(data []byte) []string {
+	vals := []string{}
+	for line := range bytes.Lines(data) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || string(line) == fuzzCorpusHeader {
+			continue
+		}
+
+		vals = append(vals, string(line))
+	}
+
+	return vals
+}
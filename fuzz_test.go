@@ -0,0 +1,119 @@
+package gorepo
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+)
+
+func TestIsFuzzName(t *testing.T) {
+	for name, want := range map[string]bool{
+		"Fuzz":       true,
+		"FuzzParse":  true,
+		"Fuzz_parse": true,
+		"Fuzzy":      false,
+		"TestFuzz":   false,
+	} {
+		if got := isFuzzName(name); got != want {
+			t.Errorf("isFuzzName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFuzzFuncs(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "a_test.go", `package a
+
+import tst "testing"
+
+func FuzzA(f *tst.F) {}
+
+func FuzzB(t *tst.T) {}
+
+func Fuzzy(f *tst.F) {}
+
+func FuzzC(f *tst.F, x int) {}
+
+func helper() {}
+`, parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fuzzFuncs(file); !slices.Equal(got, []string{"FuzzA"}) {
+		t.Errorf("fuzzFuncs() = %v", got)
+	}
+}
+
+func TestCorpusValues(t *testing.T) {
+	got := corpusValues([]byte("go test fuzz v1\nstring(\"x\")\nint(5)\n"))
+	if want := []string{`string("x")`, "int(5)"}; !slices.Equal(got, want) {
+		t.Errorf("corpusValues() = %q, want %q", got, want)
+	}
+}
+
+func TestFuzz(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"p/p_test.go": `package p
+
+import "testing"
+
+func FuzzLong(f *testing.F) {
+	f.Add("abc")
+	f.Fuzz(func(t *testing.T, s string) {
+		if len(s) > 4 {
+			t.Fatal("too long")
+		}
+	})
+}
+
+func FuzzFine(f *testing.F) {
+	f.Add(1)
+	f.Fuzz(func(t *testing.T, n int) {})
+}
+`,
+		"other/other_test.go": "package other\n\nimport \"testing\"\n\nfunc TestOther(t *testing.T) {}\n",
+	})
+
+	targets, err := repo.FuzzTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(targets) != 2 || targets[0].Name != "FuzzFine" || targets[1].Package != "example.com/m/p" ||
+		targets[1].Dir != "p" {
+		t.Fatalf("unexpected targets %+v", targets)
+	}
+
+	runs, err := repo.Fuzz(context.Background(), FuzzOptions{FuzzTime: time.Second, Parallel: 2, Workers: 1, Commit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(runs) != 2 || len(runs[0].Findings) != 0 || len(runs[1].Findings) != 1 {
+		t.Fatalf("unexpected runs %+v", runs)
+	}
+
+	finding := runs[1].Findings[0]
+	if filepath.Dir(finding.File) != filepath.Join("p", "testdata", "fuzz", "FuzzLong") ||
+		len(finding.Values) != 1 || !strings.HasPrefix(finding.Values[0], "string(") {
+		t.Errorf("unexpected finding %+v", finding)
+	}
+
+	if status, err := repo.GitStatus(); err != nil {
+		t.Fatal(err)
+	} else if s, ok := status[filepath.ToSlash(finding.File)]; ok && s.Worktree != git.Unmodified {
+		t.Errorf("finding not committed: %+v", s)
+	}
+}
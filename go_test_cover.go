@@ -1,78 +1,255 @@
 package gorepo
 
 import (
-	"bufio"
-	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/MarkRosemaker/ghrepo"
 )
 
-var reCover = regexp.MustCompile(`^total:\t+\(statements\)\t+([0-9]+.[0-9])%$`)
+// noTestPackagesMsg is the output of go test in a module without packages.
+const noTestPackagesMsg = "go: warning: \"./...\" matched no packages\nno packages to test"
 
+// ModuleCoverage is the test coverage of a single module.
+type ModuleCoverage struct {
+	// Module is the module the tests were run in.
+	Module GoModule
+	// Coverage is the percentage of covered statements.
+	Coverage float64
+	// Statements is the number of statements in the coverage profile.
+	Statements int
+	// Mode is the coverage mode of the profile: "set", "count" or "atomic".
+	Mode string
+	// Packages is the coverage per package, sorted by import path.
+	Packages []PackageCoverage
+	// Profile is the path of the coverage profile if it was kept, i.e. if CoverOptions.ProfileDir was set.
+	Profile string
+}
+
+// CoverOptions configures GoTestCoverWithOptions.
+type CoverOptions struct {
+	TestOptions
+	// CoverMode is "set", "count" or "atomic".
+	// If empty, the go command uses "atomic" with the race detector and "set" otherwise.
+	CoverMode string
+	// CoverPkg are the patterns of the packages to record coverage for, e.g. "./..." to count statements
+	// covered by the tests of other packages. If empty, each package records only its own coverage.
+	CoverPkg []string
+	// ProfileDir is the directory to keep the coverage profiles in, one per module.
+	// A relative directory is relative to the current working directory, not to the repository or module.
+	// If empty, the profiles are written to a temporary directory that is removed afterwards.
+	ProfileDir string
+}
+
+// DefaultCoverOptions returns the options GoTestCover uses:
+// race detection, atomic coverage mode and shuffled tests.
+func DefaultCoverOptions() CoverOptions {
+	return CoverOptions{
+		TestOptions: TestOptions{Race: true, Shuffle: true},
+		CoverMode:   "atomic",
+	}
+}
+
+// CoverResult is the test coverage of the repository.
+type CoverResult struct {
+	// Coverage is the statement coverage of all modules combined.
+	Coverage float64
+	// Modules is the coverage per module.
+	Modules []ModuleCoverage
+	// Seed is the seed the tests were shuffled with, or zero if they were not shuffled.
+	// Set it as TestOptions.Seed to replay the order.
+	Seed int64
+}
+
+// GoTestCover runs the tests of every module with coverage enabled
+// and returns the statement coverage of all modules combined.
 func (r *Repository) GoTestCover(ctx context.Context) (float64, error) {
-	const coverFile = "cover.out"
-	defer func() { _ = r.Remove(coverFile) }() // always clean up, even on early errors
+	res, err := r.GoTestCoverWithOptions(ctx, DefaultCoverOptions())
+	if err != nil {
+		return 0, err
+	}
+
+	return res.Coverage, nil
+}
+
+// GoTestCoverModules runs the tests of every module with coverage enabled
+// and returns the coverage per module.
+func (r *Repository) GoTestCoverModules(ctx context.Context) ([]ModuleCoverage, error) {
+	res, err := r.GoTestCoverWithOptions(ctx, DefaultCoverOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Modules, nil
+}
+
+// GoTestCoverWithOptions runs the tests of every module with coverage enabled, as configured by opts,
+// and returns the coverage per module and combined, along with the shuffle seed used.
+// If the tests of a module fail, the result is returned together with the error, holding the seed
+// to replay the order and the coverage of the other modules. Nothing is written to the repository.
+func (r *Repository) GoTestCoverWithOptions(ctx context.Context, opts CoverOptions) (*CoverResult, error) {
+	profileDir := opts.ProfileDir
+	if profileDir == "" {
+		tmp, err := os.MkdirTemp("", "gorepo-cover-*")
+		if err != nil {
+			return nil, fmt.Errorf("creating directory for coverage profiles: %w", err)
+		}
+		defer os.RemoveAll(tmp) //nolint:errcheck
+
+		profileDir = tmp
+	} else {
+		// go test resolves a relative profile against the module directory, but it is read back from the working directory
+		abs, err := filepath.Abs(profileDir)
+		if err != nil {
+			return nil, fmt.Errorf("resolving directory for coverage profiles: %w", err)
+		}
+
+		if err := os.MkdirAll(abs, 0o755); err != nil {
+			return nil, fmt.Errorf("creating directory for coverage profiles: %w", err)
+		}
+
+		profileDir = abs
+	}
+
+	res := &CoverResult{Modules: []ModuleCoverage{}, Seed: opts.shuffleSeed()}
+	err := r.forEachModule(func(m GoModule) error {
+		cov, err := r.goTestCover(ctx, m, opts, res.Seed, filepath.Join(profileDir, coverProfileName(m)))
+		if err != nil {
+			return err
+		}
+
+		if opts.ProfileDir == "" {
+			cov.Profile = ""
+		}
+
+		res.Modules = append(res.Modules, cov)
+
+		return nil
+	})
+
+	res.Coverage = totalCoverage(res.Modules)
+
+	return res, err
+}
+
+// coverProfileName returns the file name of the coverage profile of the module, e.g. "cover.out"
+// for the root module and "cover_tools_lint.out" for the module in tools/lint.
+func coverProfileName(m GoModule) string { return moduleFileName(m, "cover", ".out") }
+
+// moduleFileName returns a file name for an output of the module that is distinct for every module,
+// base+ext for the root module and base, the module directory with underscores and ext for the others.
+func moduleFileName(m GoModule, base, ext string) string {
+	if m.Dir == "." {
+		return base + ext
+	}
+
+	return base + "_" + strings.ReplaceAll(filepath.ToSlash(m.Dir), "/", "_") + ext
+}
+
+func (r *Repository) goTestCover(ctx context.Context, m GoModule, opts CoverOptions, seed int64, profile string) (ModuleCoverage, error) {
+	cov := ModuleCoverage{Module: m}
+
+	args := []string{"test", "-cover", "-coverprofile=" + profile}
+	if opts.CoverMode != "" {
+		args = append(args, "-covermode="+opts.CoverMode)
+	}
+
+	if len(opts.CoverPkg) > 0 {
+		args = append(args, "-coverpkg="+strings.Join(opts.CoverPkg, ","))
+	}
 
 	// run go test with coverage
-	if _, err := r.ExecCommand(
-		ctx, "go", "test", "./...",
-		"-race", // enable race detection
-		// enable coverage and write to cover.out
-		"-cover", "-covermode=atomic", "-coverprofile="+coverFile,
-		"-shuffle=on", // shuffle tests
-	); err != nil {
-		const noTestPackagesMsg = "go: warning: \"./...\" matched no packages\nno packages to test"
+	if _, err := r.execEnvIn(ctx, m.Dir, opts.Env, "go", append(args, opts.args(seed)...)...); err != nil {
 		if execErr := (ghrepo.ExecError{}); errors.As(err, &execErr) &&
 			execErr.Out == noTestPackagesMsg {
-			return 0, nil
+			return cov, nil
 		}
 
-		return 0, err
+		return cov, err
 	}
 
-	// get coverage from the go test
-	out, err := r.ExecCommand(ctx, "go", "tool", "cover", "-func="+coverFile)
+	cov.Profile = profile
+
+	f, err := os.Open(profile)
 	if err != nil {
-		return 0, fmt.Errorf("getting go test coverage: %w", err)
+		return cov, fmt.Errorf("opening coverage profile: %w", err)
 	}
+	defer f.Close() //nolint:errcheck
 
-	sc := bufio.NewScanner(bytes.NewReader(out))
-
-	// scan until we find the total coverage line
-	totalLine, err := getTotalLine(sc)
+	profiles, err := ParseProfiles(f)
 	if err != nil {
-		return 0, err
+		return cov, err
 	}
 
-	match := reCover.FindStringSubmatch(totalLine)
-	if match == nil {
-		return 0, fmt.Errorf("failed to parse go coverage line %q", totalLine)
+	if err := r.setCoverage(&cov, profiles); err != nil {
+		return cov, err
 	}
 
-	coverage, err := strconv.ParseFloat(match[1], 64)
+	return cov, nil
+}
+
+// setCoverage sets the mode, packages and totals of the module coverage from the profiles of its files.
+func (r Repository) setCoverage(cov *ModuleCoverage, profiles []*Profile) error {
+	pkgs, counts, err := r.coverageTree(cov.Module, profiles)
 	if err != nil {
-		return 0, fmt.Errorf("parsing coverage %q", match[1])
+		return err
+	}
+
+	if len(profiles) > 0 {
+		cov.Mode = profiles[0].Mode
 	}
 
-	return coverage, nil
+	cov.Packages = pkgs
+	cov.Statements = counts.Statements
+	cov.Coverage = counts.Percent()
+
+	return nil
 }
 
-func getTotalLine(sc *bufio.Scanner) (string, error) {
-	for sc.Scan() {
-		if strings.HasPrefix(sc.Text(), "total:") {
-			return sc.Text(), nil
+// totalCoverage combines the coverage of several modules, weighted by their number of statements.
+func totalCoverage(covs []ModuleCoverage) float64 {
+	covered, total := 0.0, 0
+	for _, c := range covs {
+		covered += c.Coverage * float64(c.Statements)
+		total += c.Statements
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return covered / float64(total)
+}
+
+// WorstCovered returns the n packages with the lowest coverage, lowest first,
+// or all packages if n is not positive. Packages without statements are left out.
+// Among packages with the same coverage, those with more uncovered statements come first.
+func (res *CoverResult) WorstCovered(n int) []PackageCoverage {
+	pkgs := []PackageCoverage{}
+	for _, m := range res.Modules {
+		for _, p := range m.Packages {
+			if p.Statements > 0 {
+				pkgs = append(pkgs, p)
+			}
 		}
 	}
 
-	if err := sc.Err(); err != nil {
-		return "", fmt.Errorf("scanning coverage output: %w", err)
+	slices.SortStableFunc(pkgs, func(a, b PackageCoverage) int {
+		return cmp.Or(
+			cmp.Compare(a.Percent(), b.Percent()),
+			cmp.Compare(b.Statements-b.Covered, a.Statements-a.Covered),
+		)
+	})
+
+	if n > 0 && n < len(pkgs) {
+		pkgs = pkgs[:n]
 	}
 
-	return "", errors.New("no 'total:' line found in coverage report")
+	return pkgs
 }
@@ -1,44 +1,137 @@
 package gorepo
 
 import (
-	"bufio"
+	"context"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/spf13/afero"
 )
 
-func TestGetTotalLine(t *testing.T) {
-	tests := []struct {
-		name    string
-		input   string
-		want    string
-		wantErr bool
-	}{
-		{
-			name:  "finds total line",
-			input: "pkg/foo.go:10:\tFunc\t100.0%\ntotal:\t(statements)\t85.3%\n",
-			want:  "total:\t(statements)\t85.3%",
-		},
-		{
-			name:    "no total line",
-			input:   "pkg/foo.go:10:\tFunc\t100.0%\n",
-			wantErr: true,
-		},
-		{
-			name:    "empty input",
-			input:   "",
-			wantErr: true,
+func TestTotalCoverage(t *testing.T) {
+	got := totalCoverage([]ModuleCoverage{
+		{Coverage: 100, Statements: 30},
+		{Coverage: 50, Statements: 10},
+		{Coverage: 0, Statements: 0},
+	})
+	if got != 87.5 {
+		t.Errorf("totalCoverage() = %v, want 87.5", got)
+	}
+
+	if got := totalCoverage(nil); got != 0 {
+		t.Errorf("totalCoverage(nil) = %v, want 0", got)
+	}
+}
+
+func TestGoTestCoverWithOptions(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"m.go":   "package m\n\nfunc Covered() int { return 1 }\n\nfunc Uncovered() int { return 2 }\n",
+		"m_test.go": `package m
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCovered(t *testing.T) {
+	if os.Getenv("GOREPO_TEST") != "yes" {
+		t.Fatal("environment not passed")
+	}
+
+	Covered()
+}
+
+func TestSkipped(t *testing.T) { Uncovered() }
+`,
+		"sub/go.mod": "module example.com/m/sub\n\ngo 1.24\n",
+	})
+
+	profileDir := t.TempDir()
+
+	res, err := repo.GoTestCoverWithOptions(context.Background(), CoverOptions{
+		TestOptions: TestOptions{
+			Skip:    "TestSkipped",
+			Count:   1,
+			Env:     []string{"GOREPO_TEST=yes"},
+			Shuffle: true,
 		},
+		CoverMode:  "count",
+		ProfileDir: profileDir,
+	})
+	if err != nil {
+		t.Fatal(err)
 	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sc := bufio.NewScanner(strings.NewReader(tt.input))
-			got, err := getTotalLine(sc)
-			if (err != nil) != tt.wantErr {
-				t.Fatalf("getTotalLine() error = %v, wantErr %v", err, tt.wantErr)
-			}
-			if got != tt.want {
-				t.Errorf("getTotalLine() = %q, want %q", got, tt.want)
-			}
-		})
+
+	if res.Seed == 0 {
+		t.Error("no shuffle seed reported")
+	}
+
+	if res.Coverage != 50 {
+		t.Errorf("Coverage = %v, want 50", res.Coverage)
+	}
+
+	if len(res.Modules) != 2 {
+		t.Fatalf("got %d modules, want 2", len(res.Modules))
+	}
+
+	if want := filepath.Join(profileDir, "cover.out"); res.Modules[0].Profile != want {
+		t.Errorf("Profile = %q, want %q", res.Modules[0].Profile, want)
+	}
+
+	profile, err := os.ReadFile(filepath.Join(profileDir, "cover.out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(profile), "mode: count\n") {
+		t.Errorf("unexpected profile %q", profile)
+	}
+
+	if ok, _ := afero.Exists(repo, "cover.out"); ok {
+		t.Error("coverage profile written to the repository")
+	}
+}
+
+func TestGoTestCoverWithOptions_Failure(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":         "module example.com/m\n\ngo 1.24\n",
+		"m.go":           "package m\n\nfunc M() int { return 1 }\n",
+		"m_test.go":      "package m\n\nimport \"testing\"\n\nfunc TestM(t *testing.T) { t.Fatal(\"broken\") }\n",
+		"sub/go.mod":     "module example.com/m/sub\n\ngo 1.24\n",
+		"sub/s.go":       "package sub\n\nfunc S() int { return 1 }\n",
+		"sub/s_test.go":  "package sub\n\nimport \"testing\"\n\nfunc TestS(t *testing.T) { S() }\n",
+		"sub/s2_test.go": "package sub\n\nimport \"testing\"\n\nfunc TestS2(t *testing.T) {}\n",
+	})
+
+	// a relative profile directory is relative to the working directory, not to the module directories
+	t.Chdir(t.TempDir())
+
+	res, err := repo.GoTestCoverWithOptions(context.Background(), CoverOptions{
+		TestOptions: TestOptions{Shuffle: true},
+		ProfileDir:  "profiles",
+	})
+	if err == nil {
+		t.Fatal("expected the failing test to cause an error")
+	}
+
+	if res == nil || res.Seed == 0 {
+		t.Fatalf("no shuffle seed reported with the failure: %+v", res)
+	}
+
+	if len(res.Modules) != 1 || res.Modules[0].Module.Dir != "sub" || res.Coverage != 100 {
+		t.Errorf("unexpected coverage of the passing module %+v", res.Modules)
+	}
+
+	if _, err := os.Stat(filepath.Join("profiles", "cover_sub.out")); err != nil {
+		t.Errorf("profile not kept in the working directory: %v", err)
 	}
 }
@@ -0,0 +1,500 @@
+package gorepo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"go/version"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// GoVersionReport compares the Go version declared by a module of the repository
+// with the version its code and its dependencies actually need.
+type GoVersionReport struct {
+	// Module is the module of the repository that was analyzed.
+	Module GoModule
+	// Declared is the version of the go directive, e.g. "1.22.0".
+	Declared string
+	// Toolchain is the toolchain directive, e.g. "go1.23.4", or empty if there is none.
+	Toolchain string
+	// Required is the minimum version needed by the code of the module itself,
+	// i.e. the newest of its Requirements, or "1.0" if there are none.
+	Required string
+	// Requirements are the language features and standard library APIs newer than Go 1.0 used by the module,
+	// each with the position of one use, sorted by version in descending order.
+	Requirements []GoVersionRequirement
+	// Dependencies are the dependencies whose go directive is newer than Required,
+	// sorted by version in descending order.
+	Dependencies []DependencyGoVersion
+}
+
+// GoVersionRequirement is a language feature or standard library API and the Go version that introduced it.
+type GoVersionRequirement struct {
+	// Version is the Go version that introduced the feature, e.g. "1.22".
+	Version string
+	// Feature describes the feature, e.g. "range over int" or "slices.Contains".
+	Feature string
+	// Pos is the position of a use of the feature, e.g. "cmd/main.go:12:2", relative to the repository root.
+	Pos string
+}
+
+// DependencyGoVersion is the Go version declared by a dependency.
+type DependencyGoVersion struct {
+	// Path is the module path of the dependency.
+	Path string
+	// Version is the version of the dependency in the build list.
+	Version string
+	// GoVersion is the version of the go directive of the dependency.
+	GoVersion string
+}
+
+// Minimum returns the lowest Go version the go directive can be set to:
+// the version required by the code of the module or by its dependencies, whichever is newer.
+func (rep GoVersionReport) Minimum() string {
+	minimum := rep.Required
+	for _, d := range rep.Dependencies {
+		minimum = maxGoVersion(minimum, d.GoVersion)
+	}
+
+	return minimum
+}
+
+// Forced reports whether a dependency requires a newer Go version than the code of the module.
+func (rep GoVersionReport) Forced() bool { return len(rep.Dependencies) > 0 }
+
+// Outdated reports whether the declared Go version is lower than the minimum,
+// i.e. the code uses features the go directive does not permit.
+func (rep GoVersionReport) Outdated() bool { return compareGoVersions(rep.Declared, rep.Minimum()) < 0 }
+
+// AnalyzeGoVersions determines for every module of the repository the minimum Go version
+// that its code needs, by type-checking it for language features and uses of standard library APIs
+// newer than Go 1.0, and the dependencies that force a higher version.
+// Standard library APIs are looked up in the api directory of the Go installation.
+//
+// Files are analyzed regardless of build constraints. Code that fails to type-check,
+// e.g. because a dependency cannot be loaded, is analyzed as far as possible.
+func (r Repository) AnalyzeGoVersions(ctx context.Context) ([]GoVersionReport, error) {
+	api, err := r.stdlibAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []GoVersionReport{}
+	if err := r.forEachModule(func(m GoModule) error {
+		rep, err := r.analyzeGoVersion(ctx, m, api)
+		if err != nil {
+			return err
+		}
+
+		reports = append(reports, rep)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+func (r Repository) analyzeGoVersion(ctx context.Context, m GoModule, api map[string]string) (GoVersionReport, error) {
+	rep := GoVersionReport{Module: m, Required: "1.0"}
+
+	f, err := r.modFile(m.Dir)
+	if err != nil {
+		return rep, err
+	}
+
+	if f.Go != nil {
+		rep.Declared = f.Go.Version
+	}
+
+	if f.Toolchain != nil {
+		rep.Toolchain = f.Toolchain.Name
+	}
+
+	pkgs, err := r.parsePackages(m.Dir)
+	if err != nil {
+		return rep, err
+	}
+
+	a := &goVersionAnalyzer{api: api, found: map[string]GoVersionRequirement{}}
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error:    func(error) {}, // analyze as much as possible
+	}
+
+	for _, pkg := range pkgs {
+		a.check(conf, pkg)
+	}
+
+	for _, req := range a.found {
+		rep.Requirements = append(rep.Requirements, req)
+		rep.Required = maxGoVersion(rep.Required, req.Version)
+	}
+
+	slices.SortFunc(rep.Requirements, func(a, b GoVersionRequirement) int {
+		if c := compareGoVersions(b.Version, a.Version); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.Feature, b.Feature)
+	})
+
+	mods, err := r.listModules(ctx, m.Dir, nil, "all")
+	if err != nil {
+		return rep, err
+	}
+
+	for _, lm := range mods {
+		if lm.Main || lm.GoVersion == "" || compareGoVersions(lm.GoVersion, rep.Required) <= 0 {
+			continue
+		}
+
+		rep.Dependencies = append(rep.Dependencies, DependencyGoVersion{
+			Path:      lm.Path,
+			Version:   lm.Version,
+			GoVersion: lm.GoVersion,
+		})
+	}
+
+	slices.SortStableFunc(rep.Dependencies, func(a, b DependencyGoVersion) int {
+		return compareGoVersions(b.GoVersion, a.GoVersion)
+	})
+
+	return rep, nil
+}
+
+// goPackage is the parsed files of a package, as the go command would group them:
+// by directory and package name, so that external test packages are separate.
+type goPackage struct {
+	fset  *token.FileSet
+	files []*ast.File
+}
+
+// parsePackages parses the Go files of the module in dir, skipping the directories
+// ignored by the go command and those of nested modules.
+func (r Repository) parsePackages(dir string) ([]goPackage, error) {
+	fset := token.NewFileSet()
+	byKey := map[string]*goPackage{}
+	keys := []string{}
+
+	if err := afero.Walk(r, dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == dir {
+				return nil
+			}
+
+			if skipDir(info.Name()) {
+				return filepath.SkipDir
+			}
+
+			if _, err := r.Stat(filepath.Join(path, goModFile)); err == nil {
+				return filepath.SkipDir // nested module
+			}
+
+			return nil
+		}
+
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		data, err := afero.ReadFile(r, path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+
+		file, err := parser.ParseFile(fset, path, data, parser.SkipObjectResolution)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+
+		key := filepath.Dir(path) + " " + file.Name.Name
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = &goPackage{fset: fset}
+			keys = append(keys, key)
+		}
+
+		byKey[key].files = append(byKey[key].files, file)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	pkgs := make([]goPackage, 0, len(keys))
+	for _, key := range keys {
+		pkgs = append(pkgs, *byKey[key])
+	}
+
+	return pkgs, nil
+}
+
+// goVersionAnalyzer records the first use of every feature newer than Go 1.0.
+type goVersionAnalyzer struct {
+	api   map[string]string
+	found map[string]GoVersionRequirement
+}
+
+func (a *goVersionAnalyzer) require(fset *token.FileSet, pos token.Pos, goVersion, feature string) {
+	if _, ok := a.found[feature]; ok {
+		return
+	}
+
+	a.found[feature] = GoVersionRequirement{
+		Version: goVersion,
+		Feature: feature,
+		Pos:     fset.Position(pos).String(),
+	}
+}
+
+func (a *goVersionAnalyzer) check(conf types.Config, pkg goPackage) {
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+
+	// errors are ignored, the info is filled in as far as possible
+	_, _ = conf.Check(pkg.files[0].Name.Name, pkg.fset, pkg.files, info)
+
+	for _, file := range pkg.files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			a.inspect(pkg.fset, info, n)
+			return true
+		})
+	}
+}
+
+func (a *goVersionAnalyzer) inspect(fset *token.FileSet, info *types.Info, n ast.Node) {
+	switch n := n.(type) {
+	case *ast.FuncDecl:
+		if n.Type.TypeParams != nil {
+			a.require(fset, n.Pos(), "1.18", "generics")
+		}
+	case *ast.TypeSpec:
+		if n.TypeParams != nil {
+			if n.Assign.IsValid() {
+				a.require(fset, n.Pos(), "1.24", "generic type aliases")
+			} else {
+				a.require(fset, n.Pos(), "1.18", "generics")
+			}
+		}
+	case *ast.RangeStmt:
+		tv, ok := info.Types[n.X]
+		if !ok || tv.Type == nil {
+			return
+		}
+
+		switch u := tv.Type.Underlying().(type) {
+		case *types.Basic:
+			if u.Info()&types.IsInteger != 0 {
+				a.require(fset, n.Pos(), "1.22", "range over int")
+			}
+		case *types.Signature:
+			a.require(fset, n.Pos(), "1.23", "range over func")
+		}
+	case *ast.Ident:
+		switch obj := info.Uses[n].(type) {
+		case *types.Builtin:
+			switch obj.Name() {
+			case "min", "max", "clear":
+				a.require(fset, n.Pos(), "1.21", "builtin "+obj.Name())
+			}
+		case *types.TypeName:
+			if obj.Pkg() == nil {
+				switch obj.Name() {
+				case "any", "comparable":
+					a.require(fset, n.Pos(), "1.18", "predeclared "+obj.Name())
+				}
+			}
+		}
+	case *ast.SelectorExpr:
+		if key := apiKey(info.Uses[n.Sel]); key != "" {
+			if v, ok := a.api[key]; ok {
+				a.require(fset, n.Sel.Pos(), v, key)
+			}
+		}
+	}
+}
+
+// apiKey returns the key of the standard library object in the API table, e.g. "slices.Contains",
+// "bytes.Buffer.AvailableBuffer" for a method or "net/http.Server.Protocols" for a struct field.
+func apiKey(obj types.Object) string {
+	if obj == nil || obj.Pkg() == nil {
+		return ""
+	}
+
+	pkg := obj.Pkg().Path()
+	if strings.Contains(strings.SplitN(pkg, "/", 2)[0], ".") {
+		return "" // not in the standard library
+	}
+
+	switch obj := obj.(type) {
+	case *types.Func:
+		if recv := obj.Signature().Recv(); recv != nil {
+			if named := namedType(recv.Type()); named != nil {
+				return pkg + "." + named.Obj().Name() + "." + obj.Name()
+			}
+
+			return ""
+		}
+	case *types.Var:
+		if obj.IsField() {
+			return "" // the struct is not known from the field alone
+		}
+	}
+
+	if obj.Parent() != obj.Pkg().Scope() {
+		return ""
+	}
+
+	return pkg + "." + obj.Name()
+}
+
+func namedType(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+
+	named, _ := t.(*types.Named)
+
+	return named
+}
+
+// stdlibAPI reads the API of the standard library from the api directory of the Go installation
+// and returns the Go version that introduced each package-level object and method.
+func (r Repository) stdlibAPI(ctx context.Context) (map[string]string, error) {
+	out, err := r.execIn(ctx, ".", "go", "env", "GOROOT")
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(strings.TrimSpace(string(out)), "api")
+
+	files, err := filepath.Glob(filepath.Join(dir, "go1.*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	api := map[string]string{}
+	for _, name := range files {
+		goVersion := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(name), "go"), ".txt")
+		if err := readAPIFile(name, goVersion, api); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(api) == 0 {
+		return nil, fmt.Errorf("no API files found in %s", dir)
+	}
+
+	return api, nil
+}
+
+func readAPIFile(name, goVersion string, api map[string]string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("opening API file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key := parseAPILine(sc.Text())
+		if key == "" {
+			continue
+		}
+
+		if prev, ok := api[key]; !ok || compareGoVersions(goVersion, prev) < 0 {
+			api[key] = goVersion
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("scanning %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// parseAPILine returns the key of a line of an API file, e.g.
+// "slices.Contains" for "pkg slices, func Contains[...](...) bool" and
+// "bytes.Buffer.AvailableBuffer" for "pkg bytes, method (*Buffer) AvailableBuffer() []uint8".
+// Struct fields and interface methods are skipped.
+func parseAPILine(line string) string {
+	rest, ok := strings.CutPrefix(line, "pkg ")
+	if !ok {
+		return ""
+	}
+
+	pkg, decl, ok := strings.Cut(rest, ", ")
+	if !ok {
+		return ""
+	}
+
+	pkg, _, _ = strings.Cut(pkg, " ") // drop the platform, e.g. "syscall (linux-386)"
+
+	kind, decl, ok := strings.Cut(decl, " ")
+	if !ok {
+		return ""
+	}
+
+	switch kind {
+	case "func", "const", "var":
+		return pkg + "." + identPrefix(decl)
+	case "type":
+		name := identPrefix(decl)
+		if rest := decl[len(name):]; strings.HasPrefix(rest, " struct, ") || strings.HasPrefix(rest, " interface, ") {
+			return ""
+		}
+
+		return pkg + "." + name
+	case "method":
+		// e.g. "(*Buffer) AvailableBuffer() []uint8" or "(*Pointer[$0]) Load() *$0"
+		recv, method, ok := strings.Cut(strings.TrimPrefix(decl, "("), ") ")
+		if !ok {
+			return ""
+		}
+
+		return pkg + "." + identPrefix(strings.TrimPrefix(recv, "*")) + "." + identPrefix(method)
+	default:
+		return ""
+	}
+}
+
+// identPrefix returns the identifier at the start of s.
+func identPrefix(s string) string {
+	if i := strings.IndexFunc(s, func(r rune) bool {
+		return r != '_' && !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
+
+// compareGoVersions compares Go versions as written in go.mod, e.g. "1.21" and "1.21.0".
+func compareGoVersions(a, b string) int { return version.Compare("go"+a, "go"+b) }
+
+func maxGoVersion(a, b string) string {
+	if compareGoVersions(a, b) < 0 {
+		return b
+	}
+
+	return a
+}
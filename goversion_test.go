@@ -0,0 +1,132 @@
+package gorepo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseAPILine(t *testing.T) {
+	for _, tc := range []struct {
+		line, want string
+	}{
+		{"pkg slices, func Contains[$0 interface{ ~[]$1 }, $1 comparable]($0, $1) bool #57433", "slices.Contains"},
+		{"pkg bytes, method (*Buffer) AvailableBuffer() []uint8 #53685", "bytes.Buffer.AvailableBuffer"},
+		{"pkg sync/atomic, method (*Pointer[$0]) Load() *$0 #50860", "sync/atomic.Pointer.Load"},
+		{"pkg net/http, const StatusTooEarly = 425", "net/http.StatusTooEarly"},
+		{"pkg io/fs, var ErrNotExist error", "io/fs.ErrNotExist"},
+		{"pkg iter, type Seq[$0 interface{}] func(func($0) bool)", "iter.Seq"},
+		{"pkg syscall (linux-386), func Pipe2([]int, int) error", "syscall.Pipe2"},
+		{"pkg crypto/tls, type Config struct, EncryptedClientHelloConfigList []uint8 #63369", ""},
+		{"pkg io/fs, type ReadDirFS interface, ReadDir(string) ([]DirEntry, error)", ""},
+		{"", ""},
+	} {
+		if got := parseAPILine(tc.line); got != tc.want {
+			t.Errorf("parseAPILine(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestAnalyzeGoVersions(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.20\n\ntoolchain go1.24.0\n",
+		"m.go": `package m
+
+import (
+	"iter"
+	"slices"
+	"strings"
+)
+
+func Sum(n int) (s int) {
+	for i := range n {
+		s += i
+	}
+
+	return max(s, 0)
+}
+
+func All(seq iter.Seq[string]) []string {
+	out := []string{}
+	for s := range seq {
+		out = append(out, strings.ToUpper(s))
+	}
+
+	return slices.Clip(out)
+}
+`,
+		"old/old.go": "package old\n\nimport \"strings\"\n\nfunc Upper(s string) string { return strings.ToUpper(s) }\n",
+	})
+
+	reports, err := repo.AnalyzeGoVersions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+
+	rep := reports[0]
+	if rep.Declared != "1.20" || rep.Toolchain != "go1.24.0" {
+		t.Errorf("declared %q, toolchain %q", rep.Declared, rep.Toolchain)
+	}
+
+	if rep.Required != "1.23" {
+		t.Errorf("Required = %q, want %q", rep.Required, "1.23")
+	}
+
+	want := map[string]string{
+		"range over func": "1.23",
+		"iter.Seq":        "1.23",
+		"range over int":  "1.22",
+		"builtin max":     "1.21",
+		"slices.Clip":     "1.21",
+	}
+
+	found := map[string]GoVersionRequirement{}
+	for _, req := range rep.Requirements {
+		found[req.Feature] = req
+	}
+
+	for feature, v := range want {
+		if req, ok := found[feature]; !ok {
+			t.Errorf("feature %q not found in %v", feature, rep.Requirements)
+		} else if req.Version != v {
+			t.Errorf("feature %q requires %q, want %q", feature, req.Version, v)
+		}
+	}
+
+	if req := found["range over int"]; req.Pos != "m.go:10:2" {
+		t.Errorf("range over int found at %q, want %q", req.Pos, "m.go:10:2")
+	}
+
+	if _, ok := found["strings.ToUpper"]; ok {
+		t.Error("Go 1.0 API reported as requirement")
+	}
+
+	if rep.Forced() || rep.Minimum() != "1.23" || !rep.Outdated() {
+		t.Errorf("Forced() = %v, Minimum() = %q, Outdated() = %v", rep.Forced(), rep.Minimum(), rep.Outdated())
+	}
+}
+
+func TestGoVersionReport_Minimum(t *testing.T) {
+	rep := GoVersionReport{
+		Declared: "1.22.0",
+		Required: "1.21",
+		Dependencies: []DependencyGoVersion{
+			{Path: "example.com/a", Version: "v1.0.0", GoVersion: "1.22.0"},
+			{Path: "example.com/b", Version: "v1.0.0", GoVersion: "1.21.3"},
+		},
+	}
+
+	if got := rep.Minimum(); got != "1.22.0" {
+		t.Errorf("Minimum() = %q, want %q", got, "1.22.0")
+	}
+
+	if !rep.Forced() || rep.Outdated() {
+		t.Errorf("Forced() = %v, Outdated() = %v", rep.Forced(), rep.Outdated())
+	}
+}
@@ -0,0 +1,261 @@
+package gorepo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// ModuleGraph is the module requirement graph of a module of the repository, as printed by `go mod graph`.
+// Nodes are module versions, edges point from a module version to the versions it requires.
+type ModuleGraph struct {
+	// Module is the module of the repository the graph belongs to.
+	Module GoModule
+	// Main is the main module, which has no version.
+	Main module.Version
+	// Edges maps every module version to the module versions it requires.
+	Edges map[module.Version][]module.Version
+}
+
+// ModuleGraph returns the module graph of the module at the root of the repository.
+func (r Repository) ModuleGraph(ctx context.Context) (*ModuleGraph, error) {
+	return r.moduleGraph(ctx, GoModule{Dir: "."})
+}
+
+// ModuleGraphs returns the module graph of every module of the repository.
+func (r Repository) ModuleGraphs(ctx context.Context) ([]*ModuleGraph, error) {
+	graphs := []*ModuleGraph{}
+	if err := r.forEachModule(func(m GoModule) error {
+		g, err := r.moduleGraph(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		graphs = append(graphs, g)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return graphs, nil
+}
+
+func (r Repository) moduleGraph(ctx context.Context, m GoModule) (*ModuleGraph, error) {
+	out, err := r.outputIn(ctx, m.Dir, "go", "mod", "graph")
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := parseModuleGraph(out)
+	if err != nil {
+		return nil, err
+	}
+
+	g.Module = m
+	if g.Module.Path == "" {
+		g.Module.Path = g.Main.Path
+	}
+
+	return g, nil
+}
+
+// parseModuleGraph parses the output of `go mod graph`.
+// The pseudo-modules go and toolchain, which record the required Go version, are left out.
+func parseModuleGraph(out []byte) (*ModuleGraph, error) {
+	g := &ModuleGraph{Edges: map[module.Version][]module.Version{}}
+
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			continue
+		}
+
+		from, to, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid module graph line %q", line)
+		}
+
+		src, dst := parseModuleVersion(from), parseModuleVersion(to)
+
+		// the main module comes first, possibly only requiring go@ if it has no dependencies
+		if g.Main.Path == "" && src.Version == "" && !isGoPseudoModule(src.Path) {
+			g.Main = src
+			g.Edges[src] = nil
+		}
+
+		if isGoPseudoModule(dst.Path) || isGoPseudoModule(src.Path) {
+			continue
+		}
+
+		g.Edges[src] = append(g.Edges[src], dst)
+		if _, ok := g.Edges[dst]; !ok {
+			g.Edges[dst] = nil
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("scanning module graph: %w", err)
+	}
+
+	return g, nil
+}
+
+func parseModuleVersion(s string) module.Version {
+	path, version, _ := strings.Cut(s, "@")
+	return module.Version{Path: path, Version: version}
+}
+
+func isGoPseudoModule(path string) bool { return path == "go" || path == "toolchain" }
+
+// Nodes returns all module versions in the graph, sorted by path and version.
+func (g *ModuleGraph) Nodes() []module.Version {
+	nodes := make([]module.Version, 0, len(g.Edges))
+	for v := range g.Edges {
+		nodes = append(nodes, v)
+	}
+
+	module.Sort(nodes)
+
+	return nodes
+}
+
+// RequiredBy returns the module versions that require any version of the module path, sorted by path and version.
+func (g *ModuleGraph) RequiredBy(path string) []module.Version {
+	dependents := []module.Version{}
+	for from, reqs := range g.Edges {
+		if slices.ContainsFunc(reqs, func(v module.Version) bool { return v.Path == path }) {
+			dependents = append(dependents, from)
+		}
+	}
+
+	module.Sort(dependents)
+
+	return dependents
+}
+
+// Selected returns the version of the module path chosen by minimal version selection,
+// i.e. the highest version reachable from the main module, or empty if the module is not in the graph.
+func (g *ModuleGraph) Selected(path string) string {
+	if path == g.Main.Path {
+		return ""
+	}
+
+	selected := ""
+	g.walk(func(v module.Version, _ []module.Version) bool {
+		if v.Path == path && (selected == "" || semver.Compare(v.Version, selected) > 0) {
+			selected = v.Version
+		}
+
+		return false
+	})
+
+	return selected
+}
+
+// ShortestPath returns a shortest chain of requirements from the main module to the selected version
+// of the module path, starting with the main module. It returns nil if the module is not in the graph.
+func (g *ModuleGraph) ShortestPath(path string) []module.Version {
+	target := module.Version{Path: path, Version: g.Selected(path)}
+	if target.Version == "" {
+		return nil
+	}
+
+	var found []module.Version
+	g.walk(func(v module.Version, chain []module.Version) bool {
+		if v == target {
+			found = chain
+			return true
+		}
+
+		return false
+	})
+
+	return found
+}
+
+// walk visits all module versions reachable from the main module in breadth-first order,
+// passing each along with the chain of requirements leading to it, until visit returns true.
+func (g *ModuleGraph) walk(visit func(v module.Version, chain []module.Version) bool) {
+	parents := map[module.Version]module.Version{}
+	seen := map[module.Version]bool{g.Main: true}
+	queue := []module.Version{g.Main}
+
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+
+		if visit(v, chainTo(v, g.Main, parents)) {
+			return
+		}
+
+		for _, req := range g.Edges[v] {
+			if !seen[req] {
+				seen[req] = true
+				parents[req] = v
+				queue = append(queue, req)
+			}
+		}
+	}
+}
+
+// chainTo follows the parents from v back to the main module and returns the chain in forward order.
+func chainTo(v, main module.Version, parents map[module.Version]module.Version) []module.Version {
+	chain := []module.Version{v}
+	for v != main {
+		v = parents[v]
+		chain = append(chain, v)
+	}
+
+	slices.Reverse(chain)
+
+	return chain
+}
+
+// Why explains why the module or package is needed by the module at the root of the repository.
+// It returns the shortest chain of packages (or modules, if the argument is a module in the build list)
+// from the main module to it, as reported by `go mod why`, or nil if it is not needed.
+func (r Repository) Why(ctx context.Context, pkgOrModule string) ([]string, error) {
+	g, err := r.ModuleGraph(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"mod", "why"}
+	if g.Selected(pkgOrModule) != "" {
+		args = append(args, "-m")
+	}
+
+	out, err := r.outputIn(ctx, ".", "go", append(args, pkgOrModule)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseWhy(out), nil
+}
+
+// parseWhy parses the output of `go mod why` for a single argument.
+func parseWhy(out []byte) []string {
+	chain := []string{}
+
+	for line := range strings.Lines(string(out)) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "("): // e.g. "(main module does not need module x)"
+			return nil
+		}
+
+		chain = append(chain, line)
+	}
+
+	return chain
+}
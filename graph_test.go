@@ -0,0 +1,100 @@
+package gorepo
+
+import (
+	"slices"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+const testModuleGraph = `example.com/test example.com/a@v1.0.0
+example.com/test example.com/b@v1.0.0
+example.com/test go@1.26.0
+example.com/a@v1.0.0 example.com/c@v1.1.0
+example.com/b@v1.0.0 example.com/d@v0.1.0
+example.com/d@v0.1.0 example.com/c@v1.2.0
+example.com/d@v0.1.0 go@1.21
+go@1.26.0 toolchain@go1.26.0
+`
+
+func TestParseModuleGraph(t *testing.T) {
+	g, err := parseModuleGraph([]byte(testModuleGraph))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Main != (module.Version{Path: "example.com/test"}) {
+		t.Errorf("unexpected main module %v", g.Main)
+	}
+
+	nodes := g.Nodes()
+	want := []module.Version{
+		{Path: "example.com/a", Version: "v1.0.0"},
+		{Path: "example.com/b", Version: "v1.0.0"},
+		{Path: "example.com/c", Version: "v1.1.0"},
+		{Path: "example.com/c", Version: "v1.2.0"},
+		{Path: "example.com/d", Version: "v0.1.0"},
+		{Path: "example.com/test"},
+	}
+	if !slices.Equal(nodes, want) {
+		t.Errorf("Nodes() = %v, want %v", nodes, want)
+	}
+
+	if got := g.Selected("example.com/c"); got != "v1.2.0" {
+		t.Errorf("Selected() = %q, want v1.2.0", got)
+	}
+	if got := g.Selected("example.com/unknown"); got != "" {
+		t.Errorf("Selected() = %q, want empty", got)
+	}
+
+	if got, want := g.RequiredBy("example.com/c"), []module.Version{
+		{Path: "example.com/a", Version: "v1.0.0"},
+		{Path: "example.com/d", Version: "v0.1.0"},
+	}; !slices.Equal(got, want) {
+		t.Errorf("RequiredBy() = %v, want %v", got, want)
+	}
+
+	if got, want := g.ShortestPath("example.com/c"), []module.Version{
+		{Path: "example.com/test"},
+		{Path: "example.com/b", Version: "v1.0.0"},
+		{Path: "example.com/d", Version: "v0.1.0"},
+		{Path: "example.com/c", Version: "v1.2.0"},
+	}; !slices.Equal(got, want) {
+		t.Errorf("ShortestPath() = %v, want %v", got, want)
+	}
+	if got := g.ShortestPath("example.com/unknown"); got != nil {
+		t.Errorf("ShortestPath() = %v, want nil", got)
+	}
+}
+
+func TestParseModuleGraph_NoDependencies(t *testing.T) {
+	g, err := parseModuleGraph([]byte("example.com/test go@1.26.0\ngo@1.26.0 toolchain@go1.26.0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Main != (module.Version{Path: "example.com/test"}) {
+		t.Errorf("unexpected main module %v", g.Main)
+	}
+
+	if nodes := g.Nodes(); !slices.Equal(nodes, []module.Version{g.Main}) {
+		t.Errorf("Nodes() = %v, want only the main module", nodes)
+	}
+}
+
+func TestParseModuleGraph_Invalid(t *testing.T) {
+	if _, err := parseModuleGraph([]byte("invalid\n")); err == nil {
+		t.Error("expected error for invalid line")
+	}
+}
+
+func TestParseWhy(t *testing.T) {
+	got := parseWhy([]byte("# golang.org/x/text\nexample.com/test\nrsc.io/quote\ngolang.org/x/text\n"))
+	if want := []string{"example.com/test", "rsc.io/quote", "golang.org/x/text"}; !slices.Equal(got, want) {
+		t.Errorf("parseWhy() = %v, want %v", got, want)
+	}
+
+	if got := parseWhy([]byte("# golang.org/x/text\n(main module does not need module golang.org/x/text)\n")); got != nil {
+		t.Errorf("parseWhy() = %v, want nil", got)
+	}
+}
@@ -0,0 +1,249 @@
+package gorepo
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/MarkRosemaker/ghrepo"
+	"github.com/go-git/go-git/v6"
+	"golang.org/x/mod/semver"
+)
+
+// ErrUncommittedChanges is returned by operations that commit or reset the worktree
+// if the repository has uncommitted changes that would be lost.
+var ErrUncommittedChanges = errors.New("repository has uncommitted changes")
+
+// Check is a set of verifications run after a dependency upgrade.
+type Check uint8
+
+const (
+	// CheckBuild builds all packages of the module.
+	CheckBuild Check = 1 << iota
+	// CheckVet runs go vet on all packages of the module.
+	CheckVet
+	// CheckTest runs the tests of all packages of the module.
+	CheckTest
+
+	// CheckAll runs all verifications.
+	CheckAll = CheckBuild | CheckVet | CheckTest
+)
+
+// IncrementalUpdateOptions configures UpdateDependenciesIncrementally.
+type IncrementalUpdateOptions struct {
+	// Policy selects the upgrades to make.
+	Policy UpdatePolicy
+	// Group returns the name of the group of a dependency.
+	// Upgrades of the same group are applied, verified and committed together.
+	// If nil, every dependency is upgraded on its own.
+	Group func(path string) string
+	// Checks are the verifications run after each upgrade. If zero, all checks are run.
+	Checks Check
+}
+
+// IncrementalUpdateResult lists the outcome of UpdateDependenciesIncrementally.
+type IncrementalUpdateResult struct {
+	// Kept are the requirement changes that passed verification and were committed.
+	Kept []ModuleChange
+	// Rejected are the upgrades that failed and were reverted.
+	Rejected []RejectedUpdate
+}
+
+// RejectedUpdate is a group of upgrades that was reverted.
+type RejectedUpdate struct {
+	// Changes are the upgrades that were attempted.
+	Changes []ModuleChange
+	// Err is the reason the upgrades were rejected.
+	Err error
+}
+
+// UpdateDependenciesIncrementally upgrades the dependencies allowed by the policy one at a time (or one group at a time).
+// After each upgrade, it runs the configured checks in the affected module:
+// a successful upgrade is committed with a message like "deps: bump x from v1.2.0 to v1.3.0",
+// a failing one is reverted with a hard reset.
+//
+// Since it commits and resets the worktree, the repository must not have uncommitted changes.
+func (r Repository) UpdateDependenciesIncrementally(ctx context.Context, opts IncrementalUpdateOptions) (*IncrementalUpdateResult, error) {
+	if dirty, err := r.HasChanges(); err != nil {
+		return nil, err
+	} else if dirty {
+		return nil, ErrUncommittedChanges
+	}
+
+	res := &IncrementalUpdateResult{}
+	if err := r.forEachModule(func(m GoModule) error {
+		planned, err := r.planUpdates(ctx, m, opts.Policy)
+		if err != nil {
+			return err
+		}
+
+		for _, group := range groupUpdates(planned, opts.Group) {
+			if err := r.updateGroup(ctx, m, group, opts, res); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// updateGroup applies and verifies a group of upgrades and commits or reverts them.
+// Upgrades that earlier groups already made are left out, see pendingUpdates.
+// It only returns an error if the repository could not be committed or reverted.
+func (r Repository) updateGroup(ctx context.Context, m GoModule, group []ModuleChange,
+	opts IncrementalUpdateOptions, res *IncrementalUpdateResult,
+) error {
+	current, err := r.modFile(m.Dir)
+	if err != nil {
+		return err
+	}
+
+	group = pendingUpdates(group, requiredVersions(current))
+	if len(group) == 0 {
+		return nil
+	}
+
+	changes, err := r.applyUpdates(ctx, m, group, opts.Policy.Pin)
+	if err == nil {
+		err = r.verify(ctx, m, cmp.Or(opts.Checks, CheckAll))
+	}
+
+	if err != nil {
+		res.Rejected = append(res.Rejected, RejectedUpdate{Changes: group, Err: err})
+		return r.revert()
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := r.CommitAll(commitMessage(group, changes)); err != nil {
+		return err
+	}
+
+	res.Kept = append(res.Kept, changes...)
+
+	return nil
+}
+
+// verify runs the checks in the module.
+func (r Repository) verify(ctx context.Context, m GoModule, checks Check) error {
+	if checks&CheckBuild != 0 {
+		if _, err := r.execIn(ctx, m.Dir, "go", "build", "./..."); err != nil {
+			return fmt.Errorf("build: %w", err)
+		}
+	}
+
+	if checks&CheckVet != 0 {
+		if err := r.goVet(ctx, m); err != nil {
+			return fmt.Errorf("vet: %w", err)
+		}
+	}
+
+	if checks&CheckTest != 0 {
+		if _, err := r.execIn(ctx, m.Dir, "go", "test", "./..."); err != nil {
+			if execErr := (ghrepo.ExecError{}); !errors.As(err, &execErr) ||
+				execErr.Out != noTestPackagesMsg {
+				return fmt.Errorf("test: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// revert discards all changes in the worktree, including new untracked files.
+func (r Repository) revert() error {
+	if err := r.GitReset(git.HardReset); err != nil {
+		return fmt.Errorf("resetting worktree: %w", err)
+	}
+
+	if err := r.GitClean(); err != nil {
+		return fmt.Errorf("cleaning worktree: %w", err)
+	}
+
+	return nil
+}
+
+// pendingUpdates returns the planned changes that are still to be made given the currently required versions,
+// starting from those versions. Since all upgrades are planned up front, an earlier group may have raised a module
+// to or beyond its planned version: applying that plan would downgrade the module again.
+func pendingUpdates(group []ModuleChange, required map[string]string) []ModuleChange {
+	pending := []ModuleChange{}
+	for _, c := range group {
+		if v, ok := required[c.Path]; ok {
+			if v == c.To || semver.Compare(c.To, c.From) > 0 && semver.Compare(v, c.To) >= 0 {
+				continue
+			}
+
+			c.From = v
+		}
+
+		pending = append(pending, c)
+	}
+
+	return pending
+}
+
+// groupUpdates splits the planned upgrades into the groups to apply together, in a stable order.
+func groupUpdates(planned []ModuleChange, group func(path string) string) [][]ModuleChange {
+	if group == nil {
+		groups := make([][]ModuleChange, 0, len(planned))
+		for _, c := range planned {
+			groups = append(groups, []ModuleChange{c})
+		}
+
+		return groups
+	}
+
+	byName := map[string][]ModuleChange{}
+	names := []string{}
+	for _, c := range planned {
+		name := group(c.Path)
+		if _, ok := byName[name]; !ok {
+			names = append(names, name)
+		}
+
+		byName[name] = append(byName[name], c)
+	}
+
+	slices.Sort(names)
+
+	groups := make([][]ModuleChange, 0, len(names))
+	for _, name := range names {
+		groups = append(groups, byName[name])
+	}
+
+	return groups
+}
+
+// commitMessage describes the requirement changes made by upgrading a group of dependencies.
+// The subject names the upgraded dependency (or the size of the group),
+// the body lists all changes if the upgrade moved other requirements as well.
+func commitMessage(group, changes []ModuleChange) string {
+	subject := fmt.Sprintf("deps: update %d modules", len(group))
+	if len(group) == 1 {
+		subject = "deps: " + group[0].String()
+		if i := slices.IndexFunc(changes, func(c ModuleChange) bool { return c.Path == group[0].Path }); i >= 0 {
+			subject = "deps: " + changes[i].String()
+		}
+	}
+
+	if len(changes) == 1 && len(group) == 1 {
+		return subject
+	}
+
+	lines := make([]string, 0, len(changes))
+	for _, c := range changes {
+		lines = append(lines, "- "+c.String())
+	}
+
+	return subject + "\n\n" + strings.Join(lines, "\n")
+}
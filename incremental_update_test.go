@@ -0,0 +1,173 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// setTestAuthor configures the commit author of a test repository.
+func setTestAuthor(t *testing.T, repo *Repository) {
+	t.Helper()
+
+	f, err := repo.OpenFile(".git/config", os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	if _, err := f.WriteString("[user]\n\tname = test\n\temail = test@example.com\n"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupUpdates(t *testing.T) {
+	planned := []ModuleChange{
+		{Path: "golang.org/x/net"},
+		{Path: "example.com/a"},
+		{Path: "golang.org/x/mod"},
+	}
+
+	if got := groupUpdates(planned, nil); len(got) != 3 || got[1][0].Path != "example.com/a" {
+		t.Errorf("expected one group per upgrade in planned order, got %v", got)
+	}
+
+	byHost := func(path string) string { host, _, _ := strings.Cut(path, "/"); return host }
+	got := groupUpdates(planned, byHost)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 groups, got %v", got)
+	}
+	if got[0][0].Path != "example.com/a" {
+		t.Errorf("expected groups sorted by name, got %v", got)
+	}
+	if !slices.Equal(got[1], []ModuleChange{{Path: "golang.org/x/net"}, {Path: "golang.org/x/mod"}}) {
+		t.Errorf("unexpected second group %v", got[1])
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	bumpX := ModuleChange{Path: "example.com/x", From: "v1.2.0", To: "v1.3.0"}
+	bumpY := ModuleChange{Path: "example.com/y", From: "v0.1.0", To: "v0.2.0"}
+
+	if got, want := commitMessage([]ModuleChange{bumpX}, []ModuleChange{bumpX}),
+		"deps: bump example.com/x from v1.2.0 to v1.3.0"; got != want {
+		t.Errorf("commitMessage() = %q, want %q", got, want)
+	}
+
+	if got, want := commitMessage([]ModuleChange{bumpX}, []ModuleChange{bumpX, bumpY}),
+		"deps: bump example.com/x from v1.2.0 to v1.3.0\n\n"+
+			"- bump example.com/x from v1.2.0 to v1.3.0\n"+
+			"- bump example.com/y from v0.1.0 to v0.2.0"; got != want {
+		t.Errorf("commitMessage() = %q, want %q", got, want)
+	}
+
+	if got := commitMessage([]ModuleChange{bumpX, bumpY}, []ModuleChange{bumpX, bumpY}); !strings.HasPrefix(got, "deps: update 2 modules\n\n") {
+		t.Errorf("unexpected group commit message %q", got)
+	}
+}
+
+func TestUpdateDependenciesIncrementally_UncommittedChanges(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := afero.WriteFile(repo, "go.mod", []byte("module example.com/test\n\ngo 1.26\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.UpdateDependenciesIncrementally(context.Background(), IncrementalUpdateOptions{}); !errors.Is(err, ErrUncommittedChanges) {
+		t.Errorf("expected ErrUncommittedChanges, got %v", err)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+
+	const goMod = "module example.com/test\n\ngo 1.26\n"
+	if err := afero.WriteFile(repo, "go.mod", []byte(goMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CommitAll("initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.AddRequire("example.com/a", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := afero.WriteFile(repo, "go.sum", []byte("example.com/a v1.0.0 h1:x=\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.revert(); err != nil {
+		t.Fatal(err)
+	}
+
+	if dirty, err := repo.HasChanges(); err != nil {
+		t.Fatal(err)
+	} else if dirty {
+		t.Error("expected clean worktree after revert")
+	}
+
+	data, err := afero.ReadFile(repo, "go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != goMod {
+		t.Errorf("go.mod not restored:\n%s", data)
+	}
+}
+
+func TestUpdateDependenciesIncrementally_RaisedByEarlierUpdate(t *testing.T) {
+	writeTestProxy(t, map[string][]string{
+		"example.com/a": {"v1.0.0", "v1.0.1"},
+		"example.com/b": {"v1.0.0", "v1.0.1", "v1.1.0"},
+	}, map[string][]string{
+		"example.com/a@v1.0.1": {"example.com/b v1.1.0"},
+	})
+
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/b v1.0.0\n)\n",
+		"m.go":   "package m\n\nimport (\n\t_ \"example.com/a\"\n\t_ \"example.com/b\"\n)\n",
+	})
+
+	if _, err := repo.execIn(context.Background(), ".", "go", "mod", "tidy"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.CommitAll("initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	// b is planned to v1.0.1, but upgrading a raises it to v1.1.0 first
+	res, err := repo.UpdateDependenciesIncrementally(context.Background(), IncrementalUpdateOptions{
+		Policy: UpdatePolicy{PatchOnly: true},
+		Checks: CheckBuild,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, c := range res.Kept {
+		got = append(got, c.String())
+	}
+
+	want := []string{"bump example.com/a from v1.0.0 to v1.0.1", "bump example.com/b from v1.0.0 to v1.1.0"}
+	if !slices.Equal(got, want) || len(res.Rejected) != 0 {
+		t.Errorf("kept %q, rejected %+v, want %q", got, res.Rejected, want)
+	}
+
+	f, err := repo.modFile(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if required := requiredVersions(f); required["example.com/a"] != "v1.0.1" || required["example.com/b"] != "v1.1.0" {
+		t.Errorf("requirements downgraded: %v", required)
+	}
+}
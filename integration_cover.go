@@ -0,0 +1,285 @@
+package gorepo
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// IntegrationScenario is a run of the binaries built with coverage, e.g. a command line of a CLI under test.
+type IntegrationScenario struct {
+	// Name identifies the scenario in errors.
+	Name string
+	// Command is the command to run. The binaries built from the main packages of the repository
+	// are found by their name, e.g. "mycli", before the PATH is searched.
+	Command string
+	// Args are the arguments of the command.
+	Args []string
+	// Script is a shell script to run with `sh -c` instead of a command.
+	// The binaries built from the main packages of the repository are on its PATH.
+	Script string
+	// Dir is the working directory relative to the repository root, the root if empty.
+	Dir string
+	// Env are additional environment variables, as "key=value" pairs.
+	Env []string
+	// AllowFailure does not treat a non-zero exit code as an error, e.g. for scenarios of error handling.
+	AllowFailure bool
+}
+
+// IntegrationCoverOptions configures IntegrationCover.
+type IntegrationCoverOptions struct {
+	// Cover configures the unit tests. Its coverage mode, -coverpkg patterns, build tags, race detection
+	// and environment also apply to building the binaries.
+	Cover CoverOptions
+	// Packages are the patterns of the main packages to build in every module, all main packages if empty.
+	Packages []string
+	// Scenarios are run in order with GOCOVERDIR set.
+	Scenarios []IntegrationScenario
+}
+
+// IntegrationCoverResult is the coverage of the unit tests and of the integration scenarios.
+type IntegrationCoverResult struct {
+	// Unit is the coverage of the unit tests only.
+	Unit *CoverResult
+	// Integration is the coverage of the integration scenarios only.
+	Integration *CoverResult
+	// Combined is the coverage of both, i.e. statements executed by unit tests or integration scenarios.
+	Combined *CoverResult
+}
+
+// IntegrationCover measures the coverage of the unit tests like GoTestCoverWithOptions, builds the main packages
+// of every module with `go build -cover`, runs the integration scenarios with GOCOVERDIR set
+// and converts the counters with `go tool covdata textfmt`. It returns the unit, integration and combined coverage.
+// On any error, the result is returned along with it, holding the unit coverage measured so far:
+// if the unit tests fail, the scenarios are not run and the result only holds the partial unit coverage.
+// Nothing is written to the repository, unless the scenarios do so.
+func (r *Repository) IntegrationCover(ctx context.Context, opts IntegrationCoverOptions) (*IntegrationCoverResult, error) {
+	res := &IntegrationCoverResult{}
+
+	var err error
+	if res.Unit, err = r.GoTestCoverWithOptions(ctx, opts.Cover); err != nil {
+		return res, err // the unit coverage holds the seed to replay the failure
+	}
+
+	tmp, err := os.MkdirTemp("", "gorepo-integration-*")
+	if err != nil {
+		return res, fmt.Errorf("creating directory for integration coverage: %w", err)
+	}
+	defer os.RemoveAll(tmp) //nolint:errcheck
+
+	binDirs, err := r.buildCoverBinaries(ctx, opts, filepath.Join(tmp, "bin"))
+	if err != nil {
+		return res, err
+	}
+
+	covDir := filepath.Join(tmp, "covdata")
+	if err := os.MkdirAll(covDir, 0o755); err != nil {
+		return res, fmt.Errorf("creating GOCOVERDIR: %w", err)
+	}
+
+	for _, s := range opts.Scenarios {
+		if err := r.runScenario(ctx, s, binDirs, slices.Concat(opts.Cover.Env, []string{"GOCOVERDIR=" + covDir})); err != nil {
+			return res, err
+		}
+	}
+
+	profiles, err := r.covdataProfiles(ctx, covDir, filepath.Join(tmp, "integration.out"))
+	if err != nil {
+		return res, err
+	}
+
+	mods, err := r.GoModules()
+	if err != nil {
+		return res, err
+	}
+
+	byModule := profilesByModule(mods, profiles)
+
+	// only set once complete, so that an error leaves the result with the unit coverage only
+	integrationRes := &CoverResult{Modules: []ModuleCoverage{}}
+	combinedRes := &CoverResult{Modules: []ModuleCoverage{}, Seed: res.Unit.Seed}
+
+	for _, m := range mods {
+		integration := ModuleCoverage{Module: m}
+		if err := r.setCoverage(&integration, byModule[m.Dir]); err != nil {
+			return res, err
+		}
+
+		integrationRes.Modules = append(integrationRes.Modules, integration)
+
+		combined := ModuleCoverage{Module: m}
+
+		var buf bytes.Buffer
+		for _, cov := range res.Unit.Modules {
+			if cov.Module == m && len(cov.Packages) > 0 {
+				if err := WriteCoverProfile(&buf, cov); err != nil {
+					return res, err
+				}
+			}
+		}
+
+		if len(integration.Packages) > 0 {
+			if err := WriteCoverProfile(&buf, integration); err != nil {
+				return res, err
+			}
+		}
+
+		merged, err := ParseProfiles(&buf)
+		if err != nil {
+			return res, fmt.Errorf("merging unit and integration coverage of %s: %w", m.Path, err)
+		}
+
+		if err := r.setCoverage(&combined, merged); err != nil {
+			return res, err
+		}
+
+		combinedRes.Modules = append(combinedRes.Modules, combined)
+	}
+
+	integrationRes.Coverage = totalCoverage(integrationRes.Modules)
+	combinedRes.Coverage = totalCoverage(combinedRes.Modules)
+	res.Integration, res.Combined = integrationRes, combinedRes
+
+	return res, nil
+}
+
+// coverMode returns the coverage mode of the options, defaulting like the go command.
+// The binaries must use the mode of the unit tests so that their profiles can be merged.
+func (o CoverOptions) coverMode() string {
+	if o.CoverMode != "" {
+		return o.CoverMode
+	}
+
+	if o.Race {
+		return "atomic"
+	}
+
+	return "set"
+}
+
+// buildCoverBinaries builds the main packages of every module with coverage into a directory per module
+// below dir and returns the directories.
+func (r Repository) buildCoverBinaries(ctx context.Context, opts IntegrationCoverOptions, dir string) ([]string, error) {
+	patterns := opts.Packages
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	args := []string{"build", "-cover", "-covermode=" + opts.Cover.coverMode()}
+	if len(opts.Cover.CoverPkg) > 0 {
+		args = append(args, "-coverpkg="+strings.Join(opts.Cover.CoverPkg, ","))
+	}
+
+	if len(opts.Cover.Tags) > 0 {
+		args = append(args, "-tags="+strings.Join(opts.Cover.Tags, ","))
+	}
+
+	if opts.Cover.Race {
+		args = append(args, "-race")
+	}
+
+	dirs := []string{}
+	if err := r.forEachModule(func(m GoModule) error {
+		out, err := r.outputEnvIn(ctx, m.Dir, opts.Cover.Env, "go",
+			append([]string{"list", "-f", `{{if eq .Name "main"}}{{.ImportPath}}{{end}}`}, patterns...)...)
+		if err != nil {
+			return err
+		}
+
+		pkgs := strings.Fields(string(out))
+		if len(pkgs) == 0 {
+			return nil
+		}
+
+		binDir := filepath.Join(dir, moduleFileName(m, "bin", ""))
+		if err := os.MkdirAll(binDir, 0o755); err != nil {
+			return fmt.Errorf("creating directory for binaries: %w", err)
+		}
+
+		// the trailing separator makes go build write every binary into the directory
+		buildArgs := slices.Concat(args, []string{"-o", binDir + string(filepath.Separator)}, pkgs)
+		if _, err := r.execEnvIn(ctx, m.Dir, opts.Cover.Env, "go", buildArgs...); err != nil {
+			return err
+		}
+
+		dirs = append(dirs, binDir)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return dirs, nil
+}
+
+// runScenario runs the scenario with the binaries of binDirs first on the PATH.
+func (r Repository) runScenario(ctx context.Context, s IntegrationScenario, binDirs, env []string) error {
+	path := strings.Join(append(slices.Clone(binDirs), os.Getenv("PATH")), string(os.PathListSeparator))
+	env = slices.Concat(env, s.Env, []string{"PATH=" + path})
+
+	name, args := s.Command, s.Args
+	if s.Script != "" {
+		name, args = "sh", []string{"-c", s.Script}
+	} else if !strings.ContainsRune(name, filepath.Separator) {
+		// the command is looked up in the PATH of this process, not the one of the scenario
+		for _, dir := range binDirs {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				name = filepath.Join(dir, name)
+				break
+			}
+		}
+	}
+
+	if _, err := r.execEnvIn(ctx, cmp.Or(s.Dir, "."), env, name, args...); err != nil && !s.AllowFailure {
+		return fmt.Errorf("integration scenario %s: %w", cmp.Or(s.Name, s.Command), err)
+	}
+
+	return nil
+}
+
+// covdataProfiles converts the coverage counters in covDir to a profile written to out and parses it.
+// Without counters, e.g. if no binary was run, there are no profiles.
+func (r Repository) covdataProfiles(ctx context.Context, covDir, out string) ([]*Profile, error) {
+	if entries, err := os.ReadDir(covDir); err != nil {
+		return nil, fmt.Errorf("reading GOCOVERDIR: %w", err)
+	} else if len(entries) == 0 {
+		return nil, nil
+	}
+
+	if _, err := r.execIn(ctx, ".", "go", "tool", "covdata", "textfmt", "-i="+covDir, "-o="+out); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		return nil, fmt.Errorf("opening integration coverage profile: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	return ParseProfiles(f)
+}
+
+// profilesByModule assigns the profiles to the modules their files belong to, by module directory.
+// A file belongs to the module with the longest matching path, so that nested modules get their own files.
+func profilesByModule(mods []GoModule, profiles []*Profile) map[string][]*Profile {
+	byModule := map[string][]*Profile{}
+	for _, p := range profiles {
+		best := -1
+		for i, m := range mods {
+			if _, ok := sourceFile(m, p.FileName); ok && (best < 0 || len(m.Path) > len(mods[best].Path)) {
+				best = i
+			}
+		}
+
+		if best >= 0 {
+			byModule[mods[best].Dir] = append(byModule[mods[best].Dir], p)
+		}
+	}
+
+	return byModule
+}
@@ -0,0 +1,156 @@
+package gorepo
+
+import (
+	"context"
+	"testing"
+)
+
+func TestProfilesByModule(t *testing.T) {
+	mods := []GoModule{{Dir: ".", Path: "example.com/m"}, {Dir: "tools", Path: "example.com/m/tools"}}
+	profiles := []*Profile{
+		{FileName: "example.com/m/a.go"},
+		{FileName: "example.com/m/tools/b.go"},
+		{FileName: "example.com/m/toolsx/c.go"},
+		{FileName: "example.com/other/d.go"},
+	}
+
+	byModule := profilesByModule(mods, profiles)
+	if got := byModule["."]; len(got) != 2 || got[0].FileName != "example.com/m/a.go" || got[1].FileName != "example.com/m/toolsx/c.go" {
+		t.Errorf("root module got %+v", got)
+	}
+
+	if got := byModule["tools"]; len(got) != 1 || got[0].FileName != "example.com/m/tools/b.go" {
+		t.Errorf("tools module got %+v", got)
+	}
+}
+
+func TestIntegrationCover(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"greet/greet.go": `package greet
+
+func Greet(name string) string {
+	if name == "" {
+		return "hello, stranger"
+	}
+	return "hello, " + name
+}
+`,
+		"greet/greet_test.go": `package greet
+
+import "testing"
+
+func TestGreet(t *testing.T) {
+	if Greet("") != "hello, stranger" {
+		t.Fatal("wrong greeting")
+	}
+}
+`,
+		"cmd/hello/main.go": `package main
+
+import (
+	"fmt"
+	"os"
+
+	"example.com/m/greet"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		os.Exit(2)
+	}
+	fmt.Println(greet.Greet(os.Args[1]))
+}
+`,
+	})
+
+	res, err := repo.IntegrationCover(context.Background(), IntegrationCoverOptions{
+		Scenarios: []IntegrationScenario{
+			{Name: "greet", Command: "hello", Args: []string{"gopher"}},
+			{Name: "usage", Script: "hello", AllowFailure: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkgCoverage := func(res *CoverResult, pkg string) float64 {
+		for _, p := range res.Modules[0].Packages {
+			if p.Path == pkg {
+				return p.Percent()
+			}
+		}
+
+		return -1
+	}
+
+	for _, tc := range []struct {
+		name        string
+		res         *CoverResult
+		greet, main float64
+	}{
+		{"unit", res.Unit, CoverageCounts{Statements: 3, Covered: 2}.Percent(), -1},
+		{"integration", res.Integration, CoverageCounts{Statements: 3, Covered: 2}.Percent(), 100},
+		{"combined", res.Combined, 100, 100},
+	} {
+		if got := pkgCoverage(tc.res, "example.com/m/greet"); got != tc.greet {
+			t.Errorf("%s coverage of greet = %v, want %v", tc.name, got, tc.greet)
+		}
+
+		if got := pkgCoverage(tc.res, "example.com/m/cmd/hello"); tc.main >= 0 && got != tc.main {
+			t.Errorf("%s coverage of cmd/hello = %v, want %v", tc.name, got, tc.main)
+		}
+	}
+
+	if res.Combined.Coverage <= res.Unit.Coverage || res.Combined.Coverage <= res.Integration.Coverage {
+		t.Errorf("combined coverage %v not above unit %v and integration %v",
+			res.Combined.Coverage, res.Unit.Coverage, res.Integration.Coverage)
+	}
+}
+
+func TestIntegrationCover_UnitFailure(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":    "module example.com/m\n\ngo 1.24\n",
+		"m.go":      "package m\n",
+		"m_test.go": "package m\n\nimport \"testing\"\n\nfunc TestM(t *testing.T) { t.Fatal(\"broken\") }\n",
+	})
+
+	res, err := repo.IntegrationCover(context.Background(), IntegrationCoverOptions{
+		Cover: CoverOptions{TestOptions: TestOptions{Shuffle: true}},
+	})
+	if err == nil {
+		t.Fatal("expected the failing unit test to cause an error")
+	}
+
+	if res == nil || res.Unit == nil || res.Unit.Seed == 0 {
+		t.Errorf("partial unit coverage not returned: %+v", res)
+	}
+}
+
+func TestIntegrationCover_ScenarioFailure(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":    "module example.com/m\n\ngo 1.24\n",
+		"m.go":      "package m\n\nfunc M() int { return 1 }\n",
+		"m_test.go": "package m\n\nimport \"testing\"\n\nfunc TestM(t *testing.T) { M() }\n",
+	})
+
+	res, err := repo.IntegrationCover(context.Background(), IntegrationCoverOptions{
+		Scenarios: []IntegrationScenario{{Name: "fail", Script: "exit 1"}},
+	})
+	if err == nil {
+		t.Fatal("expected the failing scenario to cause an error")
+	}
+
+	if res == nil || res.Unit == nil || res.Unit.Coverage != 100 || res.Integration != nil || res.Combined != nil {
+		t.Errorf("unit coverage not returned on its own: %+v", res)
+	}
+}
@@ -0,0 +1,173 @@
+package gorepo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSeconds formats a duration in seconds, as JUnit XML expects.
+func junitSeconds(d time.Duration) string { return fmt.Sprintf("%.3f", d.Seconds()) }
+
+// testMessage returns the first line of the test output that was not printed by the testing package itself,
+// e.g. the message of t.Error or t.Skip, or def if there is none.
+func testMessage(output []string, def string) string {
+	for _, line := range output {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+
+		return trimmed
+	}
+
+	return def
+}
+
+// WriteJUnit writes the test report as JUnit XML: a test suite per package and a test case per test,
+// including subtests, with the failure messages and output of failed tests, skipped tests and durations.
+// A package that could not be built gets a test case with an error holding the compiler output,
+// and a package that failed without a failed test, e.g. due to a panic in TestMain, one with its output.
+func WriteJUnit(w io.Writer, rep *TestReport) error {
+	suites := junitTestSuites{}
+
+	var total time.Duration
+	for _, p := range rep.Packages {
+		if p.Status == TestSkip && len(p.Tests) == 0 {
+			continue // no test files
+		}
+
+		suite := junitTestSuite{
+			Name:      p.Package,
+			Time:      junitSeconds(p.Elapsed),
+			Cases:     []junitTestCase{},
+			SystemOut: strings.Join(p.Output, "\n"),
+		}
+
+		if rep.Seed != 0 {
+			suite.Properties = []junitProperty{{Name: "shuffle.seed", Value: fmt.Sprint(rep.Seed)}}
+		}
+
+		failedTest := false
+		for _, t := range p.Tests {
+			tc := junitTestCase{ClassName: p.Package, Name: t.Name, Time: junitSeconds(t.Elapsed)}
+			output := strings.Join(t.Output, "\n")
+
+			switch t.Status {
+			case TestFail:
+				failedTest = true
+				suite.Failures++
+
+				tc.Failure = &junitMessage{Message: testMessage(t.Output, "Failed"), Text: output}
+				if t.Panicked {
+					tc.Failure.Type = "panic"
+				}
+			case TestSkip:
+				suite.Skipped++
+				tc.Skipped = &junitMessage{Message: testMessage(t.Output, "Skipped")}
+				tc.SystemOut = output
+			default:
+				tc.SystemOut = output
+			}
+
+			suite.Cases = append(suite.Cases, tc)
+		}
+
+		switch {
+		case p.BuildFailed:
+			suite.Errors++
+			suite.Cases = append(suite.Cases, junitTestCase{
+				ClassName: p.Package,
+				Name:      "[build failed]",
+				Time:      junitSeconds(0),
+				Error:     &junitMessage{Message: "build failed", Type: "build", Text: strings.Join(p.BuildOutput, "\n")},
+			})
+		case p.Status == TestFail && !failedTest:
+			suite.Errors++
+			suite.Cases = append(suite.Cases, junitTestCase{
+				ClassName: p.Package,
+				Name:      "[package failed]",
+				Time:      junitSeconds(p.Elapsed),
+				Error:     &junitMessage{Message: testMessage(p.Output, "package failed"), Text: suite.SystemOut},
+			})
+		}
+
+		suite.Tests = len(suite.Cases)
+
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		total += p.Elapsed
+
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	suites.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+
+	if err := enc.Encode(suites); err != nil {
+		return fmt.Errorf("encoding JUnit XML: %w", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// WriteJUnitFile writes the test report as JUnit XML, see WriteJUnit, to the named file on the local filesystem,
+// e.g. a path outside of the repository where CI picks up test results, so that the worktree stays clean.
+func WriteJUnitFile(name string, rep *TestReport) error {
+	return writeOSFile(name, func(w io.Writer) error { return WriteJUnit(w, rep) })
+}
@@ -0,0 +1,101 @@
+package gorepo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTestMessage(t *testing.T) {
+	output := []string{"=== RUN   TestA", "    a_test.go:5: broken", "--- FAIL: TestA (0.00s)"}
+	if got := testMessage(output, "Failed"); got != "a_test.go:5: broken" {
+		t.Errorf("testMessage() = %q", got)
+	}
+
+	if got := testMessage(output[:1], "Failed"); got != "Failed" {
+		t.Errorf("testMessage() without message = %q", got)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	rep := &TestReport{Seed: 42, Packages: []PackageResult{
+		{Package: "example.com/m/notests", Status: TestSkip},
+		{
+			Package: "example.com/m/a", Status: TestFail, Elapsed: 1500 * time.Millisecond,
+			Output: []string{"FAIL"},
+			Tests: []TestResult{
+				{Name: "TestOK", Status: TestPass, Elapsed: time.Second},
+				{Name: "TestParent", Status: TestFail},
+				{Name: "TestParent/bad", Status: TestFail, Output: []string{"=== RUN   TestParent/bad", "    a_test.go:9: broken"}},
+				{Name: "TestSkip", Status: TestSkip, Output: []string{"    a_test.go:12: not today", "--- SKIP: TestSkip (0.00s)"}},
+				{Name: "TestPanic", Status: TestFail, Panicked: true, Output: []string{"panic: boom"}},
+			},
+		},
+		{Package: "example.com/m/build", Status: TestFail, BuildFailed: true, BuildOutput: []string{"undefined: x"}},
+		{Package: "example.com/m/main", Status: TestFail, Output: []string{"panic in TestMain", "FAIL"}},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, rep); err != nil {
+		t.Fatal(err)
+	}
+
+	suites := junitTestSuites{}
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+
+	if suites.Tests != 7 || suites.Failures != 3 || suites.Errors != 2 || suites.Skipped != 1 || suites.Time != "1.500" {
+		t.Errorf("unexpected totals %+v", suites)
+	}
+
+	if len(suites.Suites) != 3 {
+		t.Fatalf("got %d suites", len(suites.Suites))
+	}
+
+	a := suites.Suites[0]
+	if a.Name != "example.com/m/a" || len(a.Properties) != 1 || a.Properties[0].Value != "42" {
+		t.Errorf("unexpected suite %+v", a)
+	}
+
+	bad := a.Cases[2]
+	if bad.Failure == nil || bad.Failure.Message != "a_test.go:9: broken" || !strings.Contains(bad.Failure.Text, "=== RUN") {
+		t.Errorf("unexpected failure %+v", bad.Failure)
+	}
+
+	if skip := a.Cases[3].Skipped; skip == nil || skip.Message != "a_test.go:12: not today" {
+		t.Errorf("unexpected skip %+v", skip)
+	}
+
+	if f := a.Cases[4].Failure; f == nil || f.Type != "panic" {
+		t.Errorf("unexpected panic %+v", f)
+	}
+
+	if e := suites.Suites[1].Cases[0].Error; e == nil || e.Type != "build" || e.Text != "undefined: x" {
+		t.Errorf("unexpected build error %+v", e)
+	}
+
+	if e := suites.Suites[2].Cases[0].Error; e == nil || e.Message != "panic in TestMain" {
+		t.Errorf("unexpected package error %+v", e)
+	}
+}
+
+func TestWriteJUnitFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "junit.xml")
+	if err := WriteJUnitFile(name, &TestReport{}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), `<testsuites tests="0"`) {
+		t.Errorf("unexpected file:\n%s", data)
+	}
+}
@@ -0,0 +1,364 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// ThirdPartyNoticesFile is the conventional name of the file listing the licenses of all dependencies.
+const ThirdPartyNoticesFile = "THIRD_PARTY_NOTICES"
+
+// ModuleLicense is the license information of a dependency of a module of the repository.
+type ModuleLicense struct {
+	// Module is the module of the repository that depends on the dependency.
+	Module GoModule
+	// Path is the module path of the dependency.
+	Path string
+	// Version is the version of the dependency in the build list.
+	Version string
+	// Files are the license files found in the root directory of the dependency.
+	Files []LicenseFile
+}
+
+// LicenseFile is a license file of a dependency.
+type LicenseFile struct {
+	// Name is the name of the file, e.g. "LICENSE".
+	Name string
+	// License is the SPDX identifier of the license, or empty if the license could not be identified.
+	License string
+	// Text is the content of the file.
+	Text string
+}
+
+// Licenses returns the distinct SPDX identifiers of the licenses identified in the license files, sorted.
+// It returns nil if no license could be identified.
+func (l ModuleLicense) Licenses() []string {
+	var ids []string
+	for _, f := range l.Files {
+		if f.License != "" && !slices.Contains(ids, f.License) {
+			ids = append(ids, f.License)
+		}
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
+
+// Licenses identifies the licenses of the dependencies in the build list of every module.
+// The license files are read from the vendor directory if the module is vendored,
+// otherwise from the module cache, downloading missing modules.
+func (r Repository) Licenses(ctx context.Context) ([]ModuleLicense, error) {
+	licenses := []ModuleLicense{}
+	if err := r.forEachModule(func(m GoModule) error {
+		found, err := r.licenses(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		licenses = append(licenses, found...)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return licenses, nil
+}
+
+func (r Repository) licenses(ctx context.Context, m GoModule) ([]ModuleLicense, error) {
+	vendored, ok, err := r.vendorModules(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		licenses := []ModuleLicense{}
+		for _, vm := range vendored {
+			if len(vm.Packages) == 0 {
+				continue // not part of the build
+			}
+
+			files, err := readLicenseFiles(r, filepath.Join(m.Dir, "vendor", filepath.FromSlash(vm.Path)))
+			if err != nil {
+				return nil, err
+			}
+
+			licenses = append(licenses, ModuleLicense{Module: m, Path: vm.Path, Version: vm.Version, Files: files})
+		}
+
+		return licenses, nil
+	}
+
+	mods, err := r.listModules(ctx, m.Dir, nil, "all")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.downloadModules(ctx, m.Dir, mods); err != nil {
+		return nil, err
+	}
+
+	licenses := []ModuleLicense{}
+	for _, lm := range mods {
+		if lm.Main {
+			continue
+		}
+
+		files, err := readLicenseFiles(afero.NewOsFs(), lm.Dir)
+		if err != nil {
+			return nil, err
+		}
+
+		licenses = append(licenses, ModuleLicense{Module: m, Path: lm.Path, Version: lm.Version, Files: files})
+	}
+
+	return licenses, nil
+}
+
+// downloadModules downloads the listed modules that are missing from the module cache and sets their directories.
+func (r Repository) downloadModules(ctx context.Context, dir string, mods []listedModule) error {
+	args := []string{"mod", "download", "-json"}
+	for _, lm := range mods {
+		if !lm.Main && lm.Dir == "" {
+			args = append(args, lm.Path+"@"+lm.Version)
+		}
+	}
+
+	if len(args) == 3 {
+		return nil
+	}
+
+	out, err := r.outputIn(ctx, dir, "go", args...)
+	if err != nil {
+		return err
+	}
+
+	downloaded, err := decodeJSONStream[listedModule](out)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range downloaded {
+		if d.Error != nil {
+			return fmt.Errorf("downloading %s@%s: %s", d.Path, d.Version, d.Error.Err)
+		}
+
+		for i := range mods {
+			if mods[i].Path == d.Path && mods[i].Version == d.Version {
+				mods[i].Dir = d.Dir
+			}
+		}
+	}
+
+	return nil
+}
+
+// readLicenseFiles reads and classifies the license files in dir.
+func readLicenseFiles(fsys afero.Fs, dir string) ([]LicenseFile, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	infos, err := afero.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	files := []LicenseFile{}
+	for _, info := range infos {
+		if info.IsDir() || !isLicenseFile(info.Name()) {
+			continue
+		}
+
+		data, err := afero.ReadFile(fsys, filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading license file: %w", err)
+		}
+
+		files = append(files, LicenseFile{
+			Name:    info.Name(),
+			License: classifyLicense(string(data)),
+			Text:    string(data),
+		})
+	}
+
+	return files, nil
+}
+
+// isLicenseFile reports whether the file name is one conventionally used for license texts,
+// e.g. LICENSE, LICENSE.md, LICENSE-APACHE, COPYING or UNLICENSE.
+func isLicenseFile(name string) bool {
+	upper := strings.ToUpper(name)
+	if strings.HasSuffix(upper, ".GO") {
+		return false
+	}
+
+	for _, prefix := range []string{"LICENSE", "LICENCE", "COPYING", "UNLICENSE"} {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// licensePatterns identify licenses by phrases that only occur in their texts.
+// The order matters: more specific licenses come before those whose phrases they contain.
+var licensePatterns = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license", "version 2.0"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "names of its contributors"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"BSL-1.0", []string{"boost software license"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+}
+
+// classifyLicense returns the SPDX identifier of the license text, or empty if it is not recognized.
+func classifyLicense(text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+
+	for _, p := range licensePatterns {
+		if !slices.ContainsFunc(p.phrases, func(phrase string) bool {
+			return !strings.Contains(normalized, phrase)
+		}) {
+			return p.id
+		}
+	}
+
+	return ""
+}
+
+// LicensePolicy decides which dependency licenses are acceptable.
+type LicensePolicy struct {
+	// Allowed are the SPDX identifiers of acceptable licenses.
+	// If not empty, every dependency must have at least one of them.
+	Allowed []string
+	// Disallowed are the SPDX identifiers of licenses no dependency may have.
+	Disallowed []string
+	// AllowUnknown accepts dependencies whose license could not be identified.
+	AllowUnknown bool
+}
+
+// LicenseViolation is a dependency whose licenses do not comply with a LicensePolicy.
+type LicenseViolation struct {
+	License ModuleLicense
+	Reason  string
+}
+
+func (v LicenseViolation) Error() string {
+	return fmt.Sprintf("%s@%s: %s", v.License.Path, v.License.Version, v.Reason)
+}
+
+// Check returns the licenses that violate the policy, joined into a single error of LicenseViolation errors.
+func (p LicensePolicy) Check(licenses []ModuleLicense) error {
+	errs := []error{}
+	for _, l := range licenses {
+		if reason := p.violation(l); reason != "" {
+			errs = append(errs, LicenseViolation{License: l, Reason: reason})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p LicensePolicy) violation(l ModuleLicense) string {
+	ids := l.Licenses()
+	if len(ids) == 0 {
+		if p.AllowUnknown {
+			return ""
+		}
+
+		return "unknown license"
+	}
+
+	for _, id := range ids {
+		if slices.Contains(p.Disallowed, id) {
+			return "disallowed license " + id
+		}
+	}
+
+	if len(p.Allowed) > 0 && !slices.ContainsFunc(ids, func(id string) bool { return slices.Contains(p.Allowed, id) }) {
+		return "license " + strings.Join(ids, ", ") + " is not allowed"
+	}
+
+	return ""
+}
+
+// WriteThirdPartyNotices writes the license texts of the dependencies,
+// e.g. to ship a THIRD_PARTY_NOTICES file next to a binary. Each module version is listed once.
+func WriteThirdPartyNotices(w io.Writer, licenses []ModuleLicense) error {
+	const separator = "================================================================================"
+
+	sorted := slices.Clone(licenses)
+	slices.SortFunc(sorted, func(a, b ModuleLicense) int {
+		return strings.Compare(a.Path+"@"+a.Version, b.Path+"@"+b.Version)
+	})
+	sorted = slices.CompactFunc(sorted, func(a, b ModuleLicense) bool {
+		return a.Path == b.Path && a.Version == b.Version
+	})
+
+	if _, err := io.WriteString(w, "This software includes the following third-party modules.\n"); err != nil {
+		return err
+	}
+
+	for _, l := range sorted {
+		ids := l.Licenses()
+		if len(ids) == 0 {
+			ids = []string{"unknown"}
+		}
+
+		if _, err := fmt.Fprintf(w, "\n%s\n%s %s\nLicense: %s\n%s\n",
+			separator, l.Path, l.Version, strings.Join(ids, ", "), separator); err != nil {
+			return err
+		}
+
+		for _, f := range l.Files {
+			if _, err := fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(f.Text)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteThirdPartyNotices identifies the licenses of all dependencies and writes their texts
+// to the named file in the repository, e.g. ThirdPartyNoticesFile to upload it with UploadReleaseBinary.
+func (r Repository) WriteThirdPartyNotices(ctx context.Context, name string) error {
+	licenses, err := r.Licenses(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, err := r.Create(name)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", name, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	if err := WriteThirdPartyNotices(f, licenses); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	return f.Close()
+}
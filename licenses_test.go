@@ -0,0 +1,123 @@
+package gorepo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestClassifyLicense(t *testing.T) {
+	for _, tt := range []struct {
+		file string
+		want string
+	}{
+		{"vendor/golang.org/x/mod/LICENSE", "BSD-3-Clause"},
+		{"vendor/github.com/spf13/afero/LICENSE.txt", "Apache-2.0"},
+		{"vendor/github.com/fatih/color/LICENSE.md", "MIT"},
+	} {
+		data, err := os.ReadFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := classifyLicense(string(data)); got != tt.want {
+			t.Errorf("classifyLicense(%s) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+
+	if got := classifyLicense("All rights reserved."); got != "" {
+		t.Errorf("classifyLicense() = %q, want empty", got)
+	}
+}
+
+func TestIsLicenseFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"LICENSE":        true,
+		"LICENSE.md":     true,
+		"license-apache": true,
+		"COPYING":        true,
+		"UNLICENSE":      true,
+		"license.go":     false,
+		"README.md":      false,
+	} {
+		if got := isLicenseFile(name); got != want {
+			t.Errorf("isLicenseFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestLicensesVendored(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":                          "module example.com/test\n\ngo 1.26\n\nrequire example.com/a v1.0.0\n",
+		"vendor/modules.txt":              "# example.com/a v1.0.0\n## explicit; go 1.22\nexample.com/a\n# example.com/b v1.0.0\n## explicit\n",
+		"vendor/example.com/a/LICENSE":    "Permission is hereby granted, free of charge, to any person",
+		"vendor/example.com/a/COPYING.go": "package a",
+	})
+
+	licenses, err := repo.Licenses(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(licenses) != 1 {
+		t.Fatalf("got %d licenses, want 1: %+v", len(licenses), licenses)
+	}
+	if l := licenses[0]; l.Path != "example.com/a" || !slices.Equal(l.Licenses(), []string{"MIT"}) {
+		t.Errorf("unexpected license %+v", l)
+	}
+}
+
+func TestLicensePolicyCheck(t *testing.T) {
+	mit := ModuleLicense{Path: "example.com/mit", Files: []LicenseFile{{License: "MIT"}}}
+	gpl := ModuleLicense{Path: "example.com/gpl", Files: []LicenseFile{{License: "GPL-3.0"}}}
+	unknown := ModuleLicense{Path: "example.com/unknown", Files: []LicenseFile{{Name: "LICENSE"}}}
+	dual := ModuleLicense{Path: "example.com/dual", Files: []LicenseFile{{License: "MIT"}, {License: "Apache-2.0"}}}
+
+	p := LicensePolicy{Allowed: []string{"MIT", "BSD-3-Clause"}, Disallowed: []string{"GPL-3.0"}}
+	if err := p.Check([]ModuleLicense{mit, dual}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err := p.Check([]ModuleLicense{mit, gpl, unknown})
+	violation := LicenseViolation{}
+	if !errors.As(err, &violation) || violation.License.Path != "example.com/gpl" {
+		t.Fatalf("expected violation for example.com/gpl, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "example.com/unknown@: unknown license") {
+		t.Errorf("expected unknown license violation, got %v", err)
+	}
+
+	p.AllowUnknown = true
+	if err := p.Check([]ModuleLicense{unknown}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWriteThirdPartyNotices(t *testing.T) {
+	w := &bytes.Buffer{}
+	if err := WriteThirdPartyNotices(w, []ModuleLicense{
+		{Path: "example.com/b", Version: "v1.0.0", Files: []LicenseFile{{License: "MIT", Text: "MIT text\n"}}},
+		{Path: "example.com/a", Version: "v0.1.0", Files: []LicenseFile{{Text: "custom text"}}},
+		{Path: "example.com/b", Version: "v1.0.0", Files: []LicenseFile{{License: "MIT", Text: "MIT text\n"}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := w.String()
+	if strings.Count(got, "example.com/b v1.0.0") != 1 {
+		t.Errorf("expected module to be listed once:\n%s", got)
+	}
+	if strings.Index(got, "example.com/a") > strings.Index(got, "example.com/b") {
+		t.Errorf("expected modules sorted by path:\n%s", got)
+	}
+	for _, want := range []string{"License: unknown", "License: MIT", "custom text", "MIT text"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
@@ -48,7 +48,17 @@ func (r Repository) golangCILint(ctx context.Context, fix bool, linters *config.
 		args = append(args, "-c", tmp.Name())
 	}
 
-	if _, err := r.ExecCommand(ctx, "golangci-lint", args...); err != nil {
+	return r.forEachModule(func(m GoModule) error { return r.golangCILintIn(ctx, m, args...) })
+}
+
+// golangCILintIn runs golangci-lint with the given arguments in the directory of the module.
+func (r Repository) golangCILintIn(ctx context.Context, m GoModule, args ...string) error {
+	name, toolArgs, err := r.toolCommand(m, "golangci-lint")
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.execIn(ctx, m.Dir, name, append(toolArgs, args...)...); err != nil {
 		const noPackagesMsg = "level=error msg=\"Running error: context loading failed: no go files to analyze: running `go mod tidy` may solve the problem\""
 		if execErr := (ghrepo.ExecError{}); errors.As(err, &execErr) &&
 			execErr.Out == noPackagesMsg {
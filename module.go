@@ -0,0 +1,132 @@
+package gorepo
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	"golang.org/x/mod/modfile"
+)
+
+const goModFile = "go.mod"
+
+// Module returns the parsed go.mod of the repository,
+// including the go, toolchain, godebug, require, replace, exclude, retract and tool directives.
+func (r Repository) Module() (*modfile.File, error) { return r.modFile(".") }
+
+// modFile returns the parsed go.mod in dir, a directory relative to the repository root.
+func (r Repository) modFile(dir string) (*modfile.File, error) {
+	name := filepath.Join(dir, goModFile)
+
+	data, err := afero.ReadFile(r, name)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	f, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	return f, nil
+}
+
+// EditModule parses go.mod, applies the given edit and writes the result back.
+// Comments and formatting of the untouched parts of the file are preserved.
+// Nothing is written if the edit returns an error.
+func (r Repository) EditModule(edit func(f *modfile.File) error) error {
+	return r.editModFile(".", edit)
+}
+
+// editModFile is like EditModule for the go.mod in dir, a directory relative to the repository root.
+func (r Repository) editModFile(dir string, edit func(f *modfile.File) error) error {
+	f, err := r.modFile(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := edit(f); err != nil {
+		return err
+	}
+
+	f.Cleanup()
+
+	data, err := f.Format()
+	if err != nil {
+		return fmt.Errorf("formatting go.mod: %w", err)
+	}
+
+	return r.writeFile(filepath.Join(dir, goModFile), data)
+}
+
+// AddRequire sets the required version of the module path, adding a require directive if needed.
+func (r Repository) AddRequire(path, version string) error {
+	return r.EditModule(func(f *modfile.File) error {
+		return f.AddRequire(path, version)
+	})
+}
+
+// DropRequire removes the require directive for the module path.
+func (r Repository) DropRequire(path string) error {
+	return r.EditModule(func(f *modfile.File) error {
+		return f.DropRequire(path)
+	})
+}
+
+// AddReplace replaces oldPath (at oldVersion, or all versions if empty) with newPath at newVersion.
+// If newPath is a local directory, newVersion must be empty.
+func (r Repository) AddReplace(oldPath, oldVersion, newPath, newVersion string) error {
+	return r.EditModule(func(f *modfile.File) error {
+		return f.AddReplace(oldPath, oldVersion, newPath, newVersion)
+	})
+}
+
+// DropReplace removes the replace directive for oldPath at oldVersion.
+func (r Repository) DropReplace(oldPath, oldVersion string) error {
+	return r.EditModule(func(f *modfile.File) error {
+		return f.DropReplace(oldPath, oldVersion)
+	})
+}
+
+// SetGoVersion sets the go directive, e.g. "1.26.0".
+func (r Repository) SetGoVersion(version string) error {
+	return r.EditModule(func(f *modfile.File) error {
+		return f.AddGoStmt(version)
+	})
+}
+
+// SetToolchain sets the toolchain directive, e.g. "go1.26.1".
+// An empty name removes the directive.
+func (r Repository) SetToolchain(name string) error {
+	return r.EditModule(func(f *modfile.File) error {
+		if name == "" {
+			f.DropToolchainStmt()
+			return nil
+		}
+
+		return f.AddToolchainStmt(name)
+	})
+}
+
+// AddRetract retracts the versions from low to high (inclusive) with the given rationale.
+// For a single version, low and high are equal.
+func (r Repository) AddRetract(low, high, rationale string) error {
+	return r.EditModule(func(f *modfile.File) error {
+		return f.AddRetract(modfile.VersionInterval{Low: low, High: high}, rationale)
+	})
+}
+
+// writeFile writes data to the named file, keeping the permissions of an existing file.
+func (r Repository) writeFile(name string, data []byte) error {
+	perm := fs.FileMode(0o644)
+	if info, err := r.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	if err := afero.WriteFile(r, name, data, perm); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package gorepo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"golang.org/x/mod/modfile"
+)
+
+const testGoMod = `module example.com/test
+
+go 1.25.0
+
+// keep this comment
+require example.com/a v1.0.0
+
+retract v0.1.0 // published by accident
+`
+
+func TestModule(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := afero.WriteFile(repo, "go.mod", []byte(testGoMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := repo.Module()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.Module.Mod.Path; got != "example.com/test" {
+		t.Errorf("module path = %q, want %q", got, "example.com/test")
+	}
+	if got := f.Go.Version; got != "1.25.0" {
+		t.Errorf("go version = %q, want %q", got, "1.25.0")
+	}
+	if len(f.Require) != 1 || f.Require[0].Mod.Path != "example.com/a" {
+		t.Errorf("unexpected requires: %v", f.Require)
+	}
+	if len(f.Retract) != 1 {
+		t.Errorf("unexpected retracts: %v", f.Retract)
+	}
+}
+
+func TestEditModule(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := afero.WriteFile(repo, "go.mod", []byte(testGoMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, edit := range []func() error{
+		func() error { return repo.AddRequire("example.com/a", "v1.2.0") },
+		func() error { return repo.AddRequire("example.com/b", "v0.3.0") },
+		func() error { return repo.AddReplace("example.com/b", "", "../b", "") },
+		func() error { return repo.SetGoVersion("1.26.0") },
+		func() error { return repo.SetToolchain("go1.26.1") },
+		func() error { return repo.AddRetract("v1.1.0", "v1.1.2", "broken build") },
+	} {
+		if err := edit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := afero.ReadFile(repo, "go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := string(data)
+	for _, want := range []string{
+		"// keep this comment",
+		"example.com/a v1.2.0",
+		"example.com/b v0.3.0",
+		"replace example.com/b => ../b",
+		"go 1.26.0",
+		"toolchain go1.26.1",
+		"// broken build\n\t[v1.1.0, v1.1.2]",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+
+	if err := repo.DropReplace("example.com/b", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DropRequire("example.com/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetToolchain(""); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := repo.Module()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Replace) != 0 {
+		t.Errorf("expected no replace directives, got %v", f.Replace)
+	}
+	if len(f.Require) != 1 {
+		t.Errorf("expected one require directive, got %v", f.Require)
+	}
+	if f.Toolchain != nil {
+		t.Errorf("expected no toolchain directive, got %v", f.Toolchain.Name)
+	}
+}
+
+func TestEditModule_ErrorKeepsFile(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := afero.WriteFile(repo, "go.mod", []byte(testGoMod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.EditModule(func(f *modfile.File) error {
+		_ = f.DropRequire("example.com/a")
+		return f.AddGoStmt("not-a-version")
+	}); err == nil {
+		t.Fatal("expected error for invalid go version")
+	}
+
+	data, err := afero.ReadFile(repo, "go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testGoMod {
+		t.Errorf("go.mod was modified:\n%s", data)
+	}
+}
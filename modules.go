@@ -0,0 +1,170 @@
+package gorepo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+	"golang.org/x/mod/modfile"
+)
+
+const goWorkFile = "go.work"
+
+// moduleModeEnv disables workspace mode for go commands that change a single module, like go get and go mod vendor,
+// which fail or act on the whole workspace if the repository, or a directory above it, has a go.work file.
+var moduleModeEnv = []string{"GOWORK=off"}
+
+// ErrNoModules is returned by operations that run per module if the repository contains no Go module.
+var ErrNoModules = errors.New("no go.mod found in repository")
+
+// GoModule is a Go module within the repository.
+type GoModule struct {
+	// Dir is the directory of the module relative to the repository root, "." for the root module.
+	Dir string
+	// Path is the module path declared in its go.mod.
+	Path string
+}
+
+// ModuleError is an error that occurred while running an operation on one module of the repository.
+type ModuleError struct {
+	// Module is the module the operation failed for.
+	Module GoModule
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the underlying error prefixed with the module path.
+func (e ModuleError) Error() string { return fmt.Sprintf("module %s: %v", e.Module.Path, e.Err) }
+
+// Unwrap allows errors.Is and errors.As to work with ModuleError by unwrapping the underlying error.
+func (e ModuleError) Unwrap() error { return e.Err }
+
+// GoModules returns all Go modules in the repository, sorted by directory.
+//
+// It walks the repository for go.mod files, skipping vendor and testdata directories
+// as well as directories ignored by the go command (those starting with "." or "_").
+// Modules listed in the use directives of a go.work file are included as well.
+func (r Repository) GoModules() ([]GoModule, error) {
+	dirs := map[string]struct{}{}
+
+	if err := afero.Walk(r, ".", func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path != "." && skipDir(info.Name()) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if info.Name() == goModFile {
+			dirs[filepath.Dir(path)] = struct{}{}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("searching for go.mod files: %w", err)
+	}
+
+	useDirs, err := r.workspaceDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range useDirs {
+		if _, err := r.Stat(filepath.Join(dir, goModFile)); err == nil {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	mods := make([]GoModule, 0, len(dirs))
+	for dir := range dirs {
+		data, err := afero.ReadFile(r, filepath.Join(dir, goModFile))
+		if err != nil {
+			return nil, fmt.Errorf("reading go.mod: %w", err)
+		}
+
+		mods = append(mods, GoModule{Dir: dir, Path: modfile.ModulePath(data)})
+	}
+
+	slices.SortFunc(mods, func(a, b GoModule) int { return strings.Compare(a.Dir, b.Dir) })
+
+	return mods, nil
+}
+
+// hasWorkspace reports whether the repository has a go.work file at its root.
+func (r Repository) hasWorkspace() (bool, error) {
+	if _, err := r.Stat(goWorkFile); errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("checking for go.work: %w", err)
+	}
+
+	return true, nil
+}
+
+// workspaceDirs returns the directories of the use directives in go.work that lie within the repository.
+func (r Repository) workspaceDirs() ([]string, error) {
+	data, err := afero.ReadFile(r, goWorkFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading go.work: %w", err)
+	}
+
+	f, err := modfile.ParseWork(goWorkFile, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go.work: %w", err)
+	}
+
+	dirs := make([]string, 0, len(f.Use))
+	for _, u := range f.Use {
+		dir := filepath.Clean(filepath.FromSlash(u.Path))
+		if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+			continue // outside of the repository
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	return dirs, nil
+}
+
+// skipDir reports whether the directory with the given name cannot contain modules of the repository.
+func skipDir(name string) bool {
+	switch name {
+	case "vendor", "testdata":
+		return true
+	default:
+		return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+	}
+}
+
+// forEachModule calls fn for every module of the repository.
+// It does not stop at the first failure, but returns all errors joined, each wrapped in a ModuleError.
+func (r Repository) forEachModule(fn func(m GoModule) error) error {
+	mods, err := r.GoModules()
+	if err != nil {
+		return err
+	}
+
+	if len(mods) == 0 {
+		return ErrNoModules
+	}
+
+	errs := []error{}
+	for _, m := range mods {
+		if err := fn(m); err != nil {
+			errs = append(errs, ModuleError{Module: m, Err: err})
+		}
+	}
+
+	return errors.Join(errs...)
+}
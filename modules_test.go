@@ -0,0 +1,120 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func writeTestFiles(t *testing.T, repo *Repository, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		if err := repo.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := afero.WriteFile(repo, name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// unvendoredTestModule clears GOFLAGS for the test: the test module is not vendored,
+// even if this one is built with -mod=vendor.
+func unvendoredTestModule(t *testing.T) {
+	t.Helper()
+	t.Setenv("GOFLAGS", "")
+}
+
+func TestGoModules(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":                    "module example.com/test\n\ngo 1.26\n",
+		"tools/go.mod":              "module example.com/test/tools\n\ngo 1.26\n",
+		"examples/hello/go.mod":     "module example.com/test/examples/hello\n\ngo 1.26\n",
+		"vendor/example.com/go.mod": "module example.com/vendored\n",
+		"testdata/mod/go.mod":       "module example.com/testdata\n",
+		"_old/go.mod":               "module example.com/old\n",
+		"work/go.mod":               "module example.com/test/work\n\ngo 1.26\n",
+		"go.work":                   "go 1.26\n\nuse (\n\t.\n\t./testdata/mod\n\t../outside\n)\n",
+	})
+
+	mods, err := repo.GoModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []GoModule{
+		{Dir: ".", Path: "example.com/test"},
+		{Dir: "examples/hello", Path: "example.com/test/examples/hello"},
+		{Dir: "testdata/mod", Path: "example.com/testdata"}, // listed in go.work
+		{Dir: "tools", Path: "example.com/test/tools"},
+		{Dir: "work", Path: "example.com/test/work"},
+	}
+	if !slices.Equal(mods, want) {
+		t.Errorf("GoModules() = %v, want %v", mods, want)
+	}
+}
+
+func TestForEachModule_NoModules(t *testing.T) {
+	repo := newTestRepo(t)
+
+	if err := repo.forEachModule(func(GoModule) error { return nil }); !errors.Is(err, ErrNoModules) {
+		t.Errorf("expected ErrNoModules, got %v", err)
+	}
+}
+
+func TestForEachModule_JoinsErrors(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":   "module example.com/test\n",
+		"a/go.mod": "module example.com/a\n",
+		"b/go.mod": "module example.com/b\n",
+	})
+
+	errBroken := errors.New("broken")
+	visited := []string{}
+	err := repo.forEachModule(func(m GoModule) error {
+		visited = append(visited, m.Path)
+		if m.Dir == "." {
+			return nil
+		}
+
+		return errBroken
+	})
+
+	if len(visited) != 3 {
+		t.Errorf("expected all modules to be visited, got %v", visited)
+	}
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("expected wrapped error, got %v", err)
+	}
+
+	modErr := ModuleError{}
+	if !errors.As(err, &modErr) || modErr.Module.Path != "example.com/a" {
+		t.Errorf("expected ModuleError for example.com/a, got %v", err)
+	}
+}
+
+func TestGoVet_NestedModule(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":        "module example.com/test\n\ngo 1.26\n",
+		"tools/go.mod":  "module example.com/test/tools\n\ngo 1.26\n",
+		"tools/main.go": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Printf(\"%d\", \"not a number\") }\n",
+	})
+
+	err := repo.GoVet(context.Background())
+
+	modErr := ModuleError{}
+	if !errors.As(err, &modErr) {
+		t.Fatalf("expected vet error in nested module, got %v", err)
+	}
+	if modErr.Module.Dir != "tools" {
+		t.Errorf("error reported for module %q, want %q", modErr.Module.Dir, "tools")
+	}
+}
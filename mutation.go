@@ -0,0 +1,463 @@
+package gorepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MarkRosemaker/ghrepo"
+	"golang.org/x/sync/errgroup"
+)
+
+// Mutator is a kind of change mutation testing applies to the source code.
+type Mutator string
+
+const (
+	// MutateComparison flips comparison operators, e.g. "==" to "!=" and "<" to ">=".
+	MutateComparison Mutator = "comparison"
+	// MutateCondition negates the conditions of if and for statements.
+	MutateCondition Mutator = "condition"
+	// MutateArithmetic replaces arithmetic operators, e.g. "+" with "-" and "*=" with "/=".
+	MutateArithmetic Mutator = "arithmetic"
+	// MutateStatement drops statements: function calls, assignments and increments.
+	MutateStatement Mutator = "statement"
+	// MutateBoundary shifts boundaries, e.g. "<" to "<=", and increments integer constants.
+	MutateBoundary Mutator = "boundary"
+)
+
+// Mutators are all mutators, in the order their mutants are reported for the same position.
+var Mutators = []Mutator{MutateComparison, MutateCondition, MutateArithmetic, MutateStatement, MutateBoundary}
+
+// minMutantTimeout is the lowest timeout of a mutant's tests if MutationOptions.Timeout is not set.
+const minMutantTimeout = 10 * time.Second
+
+var (
+	comparisonFlips = map[token.Token]token.Token{
+		token.EQL: token.NEQ, token.NEQ: token.EQL,
+		token.LSS: token.GEQ, token.GEQ: token.LSS,
+		token.GTR: token.LEQ, token.LEQ: token.GTR,
+	}
+	boundaryShifts = map[token.Token]token.Token{
+		token.LSS: token.LEQ, token.LEQ: token.LSS,
+		token.GTR: token.GEQ, token.GEQ: token.GTR,
+	}
+	arithmeticSwaps = map[token.Token]token.Token{
+		token.ADD: token.SUB, token.SUB: token.ADD,
+		token.MUL: token.QUO, token.QUO: token.MUL, token.REM: token.MUL,
+		token.ADD_ASSIGN: token.SUB_ASSIGN, token.SUB_ASSIGN: token.ADD_ASSIGN,
+		token.MUL_ASSIGN: token.QUO_ASSIGN, token.QUO_ASSIGN: token.MUL_ASSIGN, token.REM_ASSIGN: token.MUL_ASSIGN,
+		token.INC: token.DEC, token.DEC: token.INC,
+	}
+)
+
+// MutantStatus is the outcome of running the tests against a mutant.
+type MutantStatus string
+
+const (
+	// MutantKilled means a test failed, i.e. the tests detected the change.
+	MutantKilled MutantStatus = "killed"
+	// MutantSurvived means all tests passed despite the change.
+	MutantSurvived MutantStatus = "survived"
+	// MutantTimedOut means the tests did not finish in time, e.g. due to an endless loop. It counts as killed.
+	MutantTimedOut MutantStatus = "timeout"
+	// MutantInvalid means the mutant does not build, e.g. because a dropped statement left a variable unused.
+	// It does not count towards the mutation score.
+	MutantInvalid MutantStatus = "invalid"
+)
+
+// MutationOptions configures MutationTest.
+type MutationOptions struct {
+	// Packages are the import paths of the packages to mutate. All packages of all modules are mutated if empty.
+	Packages []string
+	// Mutators are the mutators to apply, all if empty.
+	Mutators []Mutator
+	// Timeout is how long the tests of a single mutant may run.
+	// If zero, it is ten times as long as the tests took without mutations, but at least 10s.
+	Timeout time.Duration
+	// Parallel is the number of mutants tested at the same time. Zero or one tests them sequentially.
+	Parallel int
+	// Tags are the build tags to consider satisfied.
+	Tags []string
+	// Env are additional environment variables, as "key=value" pairs.
+	Env []string
+}
+
+// Mutant is a single change of the source code and the outcome of running the tests against it.
+type Mutant struct {
+	// Module is the module of the mutated file.
+	Module GoModule
+	// Package is the import path of the package of the mutated file.
+	Package string
+	// File is the mutated file relative to the repository root.
+	File string
+	// Line and Column are the position of the change in the file.
+	Line, Column int
+	// Mutator is the kind of change.
+	Mutator Mutator
+	// Original is the source code that was replaced, e.g. "<".
+	Original string
+	// Replacement is the source code it was replaced with, e.g. ">=", or empty if a statement was dropped.
+	Replacement string
+	// Status is the outcome of running the tests.
+	Status MutantStatus
+	// Output is the output of `go test`, e.g. the failure that killed the mutant.
+	Output []string
+
+	// offset and end are the byte offsets of the original source code in the file.
+	offset, end int
+}
+
+func (m Mutant) String() string {
+	change := fmt.Sprintf("%q -> %q", m.Original, m.Replacement)
+	if m.Mutator == MutateStatement {
+		change = fmt.Sprintf("dropped %q", m.Original)
+	}
+
+	if m.Status == "" {
+		return fmt.Sprintf("%s:%d:%d: %s %s", m.File, m.Line, m.Column, m.Mutator, change)
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s %s (%s)", m.File, m.Line, m.Column, m.Mutator, change, m.Status)
+}
+
+// apply returns the source code of the file with the mutation applied.
+func (m Mutant) apply(src []byte) []byte {
+	return slices.Concat(src[:m.offset], []byte(m.Replacement), src[m.end:])
+}
+
+// MutationReport is the result of MutationTest.
+type MutationReport struct {
+	// Mutants are all mutants, sorted by file and position.
+	Mutants []Mutant
+	// Timeout is how long the tests of a single mutant were allowed to run.
+	Timeout time.Duration
+}
+
+// Count returns the number of mutants with the status.
+func (rep *MutationReport) Count(status MutantStatus) int {
+	n := 0
+	for _, m := range rep.Mutants {
+		if m.Status == status {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Survived returns the mutants the tests did not detect, i.e. the changes that indicate missing tests.
+func (rep *MutationReport) Survived() []Mutant {
+	survived := []Mutant{}
+	for _, m := range rep.Mutants {
+		if m.Status == MutantSurvived {
+			survived = append(survived, m)
+		}
+	}
+
+	return survived
+}
+
+// Score returns the mutation score: the percentage of valid mutants the tests detected,
+// counting timeouts as detected. It is zero if there are no valid mutants.
+func (rep *MutationReport) Score() float64 {
+	killed := rep.Count(MutantKilled) + rep.Count(MutantTimedOut)
+
+	valid := killed + rep.Count(MutantSurvived)
+	if valid == 0 {
+		return 0
+	}
+
+	return 100 * float64(killed) / float64(valid)
+}
+
+// MutationTest runs mutation testing: it applies the mutators to the non-test Go files of the packages,
+// one change at a time, and runs the tests of the mutated package and of all packages in the module affected by it
+// against each mutant. A mutant the tests do not detect points to code that is executed but not verified,
+// which statement coverage cannot tell. Mutated files are passed to the go command with -overlay,
+// so the repository is never modified. It is an error if the tests fail without mutations.
+func (r Repository) MutationTest(ctx context.Context, opts MutationOptions) (*MutationReport, error) {
+	root, err := r.root()
+	if err != nil {
+		return nil, err
+	}
+
+	mutators := opts.Mutators
+	if len(mutators) == 0 {
+		mutators = Mutators
+	}
+
+	tmp, err := os.MkdirTemp("", "gorepo-mutation-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating directory for mutants: %w", err)
+	}
+	defer os.RemoveAll(tmp) //nolint:errcheck
+
+	var tagArgs []string
+	if len(opts.Tags) > 0 {
+		tagArgs = []string{"-tags=" + strings.Join(opts.Tags, ",")}
+	}
+
+	rep := &MutationReport{Mutants: []Mutant{}, Timeout: opts.Timeout}
+	sources := map[string][]byte{}
+	patterns := map[string][]string{}
+
+	var baseline time.Duration
+	if err := r.forEachModule(func(m GoModule) error {
+		pkgs, err := r.modulePackages(ctx, m, tagArgs...)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		if _, err := r.execEnvIn(ctx, m.Dir, opts.Env, "go", slices.Concat([]string{"test", "-count=1"}, tagArgs, []string{"./..."})...); err != nil {
+			if execErr := (ghrepo.ExecError{}); !errors.As(err, &execErr) || execErr.Out != noTestPackagesMsg {
+				return fmt.Errorf("tests fail without mutations: %w", err)
+			}
+		}
+
+		baseline += time.Since(start)
+
+		for _, p := range pkgs {
+			if len(opts.Packages) > 0 && !slices.Contains(opts.Packages, p.ImportPath) {
+				continue
+			}
+
+			for _, name := range p.GoFiles {
+				file, err := filepath.Rel(root, filepath.Join(p.Dir, name))
+				if err != nil {
+					return err
+				}
+
+				src, err := os.ReadFile(filepath.Join(p.Dir, name))
+				if err != nil {
+					return fmt.Errorf("reading %s: %w", file, err)
+				}
+
+				mutants, err := fileMutants(file, src, mutators)
+				if err != nil {
+					return err
+				}
+
+				if len(mutants) == 0 {
+					continue
+				}
+
+				am, err := affectedModule(m, root, pkgs, []string{filepath.ToSlash(file)})
+				if err != nil {
+					return err
+				}
+
+				sources[file] = src
+				patterns[file] = am.patterns()
+
+				for _, mut := range mutants {
+					mut.Module, mut.Package = m, p.ImportPath
+					rep.Mutants = append(rep.Mutants, mut)
+				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(rep.Mutants, func(a, b Mutant) int { return strings.Compare(a.File, b.File) })
+
+	if rep.Timeout <= 0 {
+		rep.Timeout = max(10*baseline, minMutantTimeout)
+	}
+
+	eg := errgroup.Group{}
+	eg.SetLimit(max(opts.Parallel, 1))
+
+	for i := range rep.Mutants {
+		eg.Go(func() error {
+			mut := &rep.Mutants[i]
+			return r.testMutant(ctx, mut, filepath.Join(root, mut.File), sources[mut.File], patterns[mut.File],
+				filepath.Join(tmp, strconv.Itoa(i)), rep.Timeout, tagArgs, opts.Env)
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return rep, nil
+}
+
+// testMutant runs the tests of the packages matching the patterns against the mutant, replacing the file
+// at the absolute path with the mutated source by an overlay in the directory prefix, and sets the mutant's status.
+func (r Repository) testMutant(ctx context.Context, mut *Mutant, file string, src []byte, patterns []string,
+	prefix string, timeout time.Duration, flags, env []string,
+) error {
+	mutated, overlay := prefix+".go", prefix+".json"
+	if err := os.WriteFile(mutated, mut.apply(src), 0o644); err != nil {
+		return fmt.Errorf("writing mutant: %w", err)
+	}
+
+	data, err := json.Marshal(map[string]map[string]string{"Replace": {file: mutated}})
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(overlay, data, 0o644); err != nil {
+		return fmt.Errorf("writing overlay: %w", err)
+	}
+
+	args := slices.Concat([]string{
+		"test", "-overlay=" + overlay, "-count=1", "-failfast", "-timeout=" + timeout.String(),
+	}, flags, patterns)
+
+	// the -timeout flag stops the test binaries, this stops a build that hangs
+	runCtx, cancel := context.WithTimeout(ctx, 2*timeout+time.Minute)
+	defer cancel()
+
+	out, err := r.execEnvIn(runCtx, mut.Module.Dir, env, "go", args...)
+	if execErr := (ghrepo.ExecError{}); errors.As(err, &execErr) {
+		out = []byte(execErr.Out)
+	}
+
+	mut.Output = strings.Split(strings.TrimSpace(string(out)), "\n")
+
+	switch output := string(out); {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case err == nil:
+		mut.Status = MutantSurvived
+	case runCtx.Err() != nil || strings.Contains(output, "panic: test timed out after"):
+		mut.Status = MutantTimedOut
+	case strings.Contains(output, "[build failed]") || strings.Contains(output, "[setup failed]"):
+		mut.Status = MutantInvalid
+	default:
+		mut.Status = MutantKilled
+	}
+
+	return nil
+}
+
+// fileMutants returns the mutants of the source file the mutators produce, sorted by position.
+// Generated files have no mutants.
+func fileMutants(name string, src []byte, mutators []Mutator) ([]Mutant, error) {
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, name, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+
+	if ast.IsGenerated(file) {
+		return nil, nil
+	}
+
+	mutants := []Mutant{}
+	add := func(mutator Mutator, pos, end token.Pos, replacement string) {
+		if !slices.Contains(mutators, mutator) {
+			return
+		}
+
+		p := fset.Position(pos)
+		offset, endOffset := p.Offset, fset.Position(end).Offset
+		mutants = append(mutants, Mutant{
+			File:        name,
+			Line:        p.Line,
+			Column:      p.Column,
+			Mutator:     mutator,
+			Original:    string(src[offset:endOffset]),
+			Replacement: replacement,
+			offset:      offset,
+			end:         endOffset,
+		})
+	}
+
+	// addOp adds a mutant replacing the operator at pos, if the mutator has a replacement for it
+	addOp := func(mutator Mutator, replacements map[token.Token]token.Token, op token.Token, pos token.Pos) {
+		if repl, ok := replacements[op]; ok {
+			add(mutator, pos, pos+token.Pos(len(op.String())), repl.String())
+		}
+	}
+
+	// addDrops adds mutants dropping the statements of a statement list
+	addDrops := func(stmts []ast.Stmt) {
+		for _, stmt := range stmts {
+			switch s := stmt.(type) {
+			case *ast.ExprStmt, *ast.IncDecStmt:
+				add(MutateStatement, s.Pos(), s.End(), "")
+			case *ast.AssignStmt:
+				if s.Tok != token.DEFINE {
+					add(MutateStatement, s.Pos(), s.End(), "")
+				}
+			}
+		}
+	}
+
+	// addNegation adds a mutant negating the condition, if there is one
+	addNegation := func(cond ast.Expr) {
+		if cond != nil {
+			start, end := fset.Position(cond.Pos()).Offset, fset.Position(cond.End()).Offset
+			add(MutateCondition, cond.Pos(), cond.End(), "!("+string(src[start:end])+")")
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ImportSpec:
+			return false
+		case *ast.ArrayType:
+			return false // changing the length of an array type rarely builds
+		case *ast.BinaryExpr:
+			addOp(MutateComparison, comparisonFlips, n.Op, n.OpPos)
+			addOp(MutateBoundary, boundaryShifts, n.Op, n.OpPos)
+
+			if !isStringLit(n.X) && !isStringLit(n.Y) {
+				addOp(MutateArithmetic, arithmeticSwaps, n.Op, n.OpPos)
+			}
+		case *ast.AssignStmt:
+			addOp(MutateArithmetic, arithmeticSwaps, n.Tok, n.TokPos)
+		case *ast.IncDecStmt:
+			addOp(MutateArithmetic, arithmeticSwaps, n.Tok, n.TokPos)
+		case *ast.IfStmt:
+			addNegation(n.Cond)
+		case *ast.ForStmt:
+			addNegation(n.Cond)
+		case *ast.BlockStmt:
+			addDrops(n.List)
+		case *ast.CaseClause:
+			addDrops(n.Body)
+		case *ast.CommClause:
+			addDrops(n.Body)
+		case *ast.BasicLit:
+			if v, err := strconv.ParseInt(n.Value, 0, 64); n.Kind == token.INT && err == nil && v < 1<<62 {
+				add(MutateBoundary, n.Pos(), n.End(), strconv.FormatInt(v+1, 10))
+			}
+		}
+
+		return true
+	})
+
+	slices.SortStableFunc(mutants, func(a, b Mutant) int {
+		if a.offset != b.offset {
+			return a.offset - b.offset
+		}
+
+		return slices.Index(Mutators, a.Mutator) - slices.Index(Mutators, b.Mutator)
+	})
+
+	return mutants, nil
+}
+
+// isStringLit reports whether the expression is a string literal, whose "+" is a concatenation.
+func isStringLit(x ast.Expr) bool {
+	lit, ok := x.(*ast.BasicLit)
+	return ok && lit.Kind == token.STRING
+}
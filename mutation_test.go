@@ -0,0 +1,146 @@
+package gorepo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFileMutants(t *testing.T) {
+	src := `// Code in a package.
+package m
+
+//go:generate echo keep directives
+
+import "fmt"
+
+func Clamp(v, hi int) int {
+	if v > hi {
+		return hi
+	}
+
+	s := "a" + "b"
+	fmt.Println(s)
+	v += 2
+
+	return v
+}
+`
+
+	mutants, err := fileMutants("m/m.go", []byte(src), Mutators)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, m := range mutants {
+		got = append(got, m.String())
+	}
+
+	want := []string{
+		`m/m.go:9:5: condition "v > hi" -> "!(v > hi)"`,
+		`m/m.go:9:7: comparison ">" -> "<="`,
+		`m/m.go:9:7: boundary ">" -> ">="`,
+		`m/m.go:14:2: statement dropped "fmt.Println(s)"`,
+		`m/m.go:15:2: statement dropped "v += 2"`,
+		`m/m.go:15:4: arithmetic "+=" -> "-="`,
+		`m/m.go:15:7: boundary "2" -> "3"`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got mutants\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	mutated := string(mutants[1].apply([]byte(src)))
+	if !strings.Contains(mutated, "if v <= hi {") || !strings.Contains(mutated, "//go:generate") {
+		t.Errorf("unexpected mutated source:\n%s", mutated)
+	}
+
+	mutants, err = fileMutants("m/m.go", []byte(src), []Mutator{MutateStatement})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mutants) != 2 {
+		t.Errorf("got %d statement mutants, want 2", len(mutants))
+	}
+
+	generated := "// Code generated by stringer. DO NOT EDIT.\n\n" + src
+	if mutants, err := fileMutants("m/m.go", []byte(generated), Mutators); err != nil || len(mutants) != 0 {
+		t.Errorf("generated file: %v, %v", mutants, err)
+	}
+}
+
+func TestMutationReport_Score(t *testing.T) {
+	rep := &MutationReport{Mutants: []Mutant{
+		{Status: MutantKilled}, {Status: MutantTimedOut}, {Status: MutantSurvived}, {Status: MutantSurvived}, {Status: MutantInvalid},
+	}}
+
+	if got := rep.Score(); got != 50 {
+		t.Errorf("Score() = %v, want 50", got)
+	}
+
+	if n := len(rep.Survived()); n != 2 {
+		t.Errorf("got %d survived mutants, want 2", n)
+	}
+
+	if got := (&MutationReport{}).Score(); got != 0 {
+		t.Errorf("Score() without mutants = %v", got)
+	}
+}
+
+func TestMutationTest(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	setTestAuthor(t, repo)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"a/a.go": "package a\n\nfunc Max(x, y int) int {\n\tif x > y {\n\t\treturn x\n\t}\n\n\treturn y\n}\n",
+		// only one case, so that the boundary mutant survives
+		"a/a_test.go": "package a\n\nimport \"testing\"\n\nfunc TestMax(t *testing.T) {\n\tif Max(2, 1) != 2 {\n\t\tt.Fatal(\"wrong\")\n\t}\n}\n",
+		"b/b.go":      "package b\n\nimport \"example.com/m/a\"\n\nfunc Double(x int) int { return a.Max(x, 0) * 2 }\n",
+	})
+
+	if err := repo.CommitAll("initial commit"); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := repo.MutationTest(context.Background(), MutationOptions{Packages: []string{"example.com/m/a"}, Parallel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := map[string]MutantStatus{}
+	for _, m := range rep.Mutants {
+		if m.Package != "example.com/m/a" || m.File != "a/a.go" {
+			t.Errorf("unexpected mutant %s", m)
+		}
+
+		status[string(m.Mutator)+" "+m.Original] = m.Status
+	}
+
+	for key, want := range map[string]MutantStatus{
+		"condition x > y":  MutantKilled,
+		"comparison >":     MutantKilled,
+		"boundary >":       MutantSurvived,
+		"statement return": "", // return statements are never dropped
+	} {
+		if got := status[key]; got != want {
+			t.Errorf("%s: got %q, want %q", key, got, want)
+		}
+	}
+
+	if got, want := rep.Score(), 100*2/3.0; got != want {
+		t.Errorf("Score() = %v, want %v", got, want)
+	}
+
+	if rep.Timeout < minMutantTimeout {
+		t.Errorf("Timeout = %v", rep.Timeout)
+	}
+
+	// the repository is left untouched
+	if changed, err := repo.GetChangedFiles(); err != nil || len(changed) != 0 {
+		t.Errorf("changed files %v, %v", changed, err)
+	}
+}
@@ -0,0 +1,317 @@
+package gorepo
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MarkRosemaker/ghrepo"
+)
+
+const (
+	raceSeparator = "=================="
+	raceHeader    = "WARNING: DATA RACE"
+)
+
+var (
+	// reRaceAccess matches e.g. "Previous write at 0x00c0000182a8 by goroutine 7:" or "Read at 0x... by main goroutine:".
+	reRaceAccess = regexp.MustCompile(`^(Previous )?(.+?) at (0x[0-9a-f]+) by (?:goroutine (\d+)|main goroutine):$`)
+	// reRaceGoroutine matches e.g. "Goroutine 8 (running) created at:".
+	reRaceGoroutine = regexp.MustCompile(`^Goroutine (\d+) \((.+?)\) created at:$`)
+	// reStackPosition matches e.g. "/tmp/m/r_test.go:7 +0x36".
+	reStackPosition = regexp.MustCompile(`^(.+):(\d+)(?: \+0x[0-9a-f]+)?$`)
+	// reFailedTest matches e.g. "--- FAIL: TestRace (0.00s)".
+	reFailedTest = regexp.MustCompile(`^\s*--- FAIL: (\S+) \(`)
+	// rePackageResult matches e.g. "FAIL	example.com/m	0.018s".
+	rePackageResult = regexp.MustCompile(`^(?:FAIL|ok )\s+(\S+)\s`)
+)
+
+// DataRace is a data race detected by the race detector, with the tests it was detected in.
+type DataRace struct {
+	// Access is the access that detected the race.
+	Access RaceAccess
+	// Previous is the earlier access it conflicts with.
+	Previous RaceAccess
+	// Goroutines are the goroutines involved, with the stacks that created them.
+	Goroutines []RaceGoroutine
+	// Occurrences are the tests the race was detected in, in the order they were reported.
+	Occurrences []RaceOccurrence
+}
+
+// RaceAccess is a memory access of a data race.
+type RaceAccess struct {
+	// Op is the kind of access, e.g. "Read", "Write" or "Atomic write".
+	Op string
+	// Addr is the memory address, e.g. "0x00c0000182a8".
+	Addr string
+	// Goroutine is the id of the goroutine that accessed the memory. The main goroutine has id 1.
+	Goroutine int
+	// Stack is the stack of the access, innermost call first.
+	Stack []RaceFrame
+}
+
+// Location returns the innermost frame of the access, i.e. where the memory was accessed.
+func (a RaceAccess) Location() RaceFrame {
+	if len(a.Stack) == 0 {
+		return RaceFrame{}
+	}
+
+	return a.Stack[0]
+}
+
+// RaceGoroutine is a goroutine involved in a data race.
+type RaceGoroutine struct {
+	// ID is the id of the goroutine.
+	ID int
+	// State is the state of the goroutine when the race was detected, e.g. "running" or "finished".
+	State string
+	// CreatedAt is the stack of the go statement that created the goroutine, innermost call first.
+	CreatedAt []RaceFrame
+}
+
+// RaceFrame is a frame of a stack reported by the race detector.
+type RaceFrame struct {
+	// Func is the function, e.g. "example.com/m.(*T).Inc".
+	Func string
+	// File is the path of the source file as printed by the race detector.
+	File string
+	// Line is the line in the source file.
+	Line int
+}
+
+func (f RaceFrame) String() string {
+	return fmt.Sprintf("%s (%s:%d)", f.Func, filepath.Base(f.File), f.Line)
+}
+
+// RaceOccurrence is a test a data race was detected in.
+type RaceOccurrence struct {
+	// Package is the import path of the package of the test, if known.
+	Package string
+	// Test is the name of the test, or empty if the race was not detected during a test, e.g. in TestMain.
+	Test string
+}
+
+// Key identifies the race by the operations and locations of both accesses, regardless of their order,
+// so that the same race detected in different tests or runs has the same key, e.g. to file one issue per race.
+func (d DataRace) Key() string {
+	a := d.Access.Op + " " + d.Access.Location().String()
+	b := d.Previous.Op + " " + d.Previous.Location().String()
+
+	if b < a {
+		a, b = b, a
+	}
+
+	return strings.ToLower(a + " / " + b)
+}
+
+func (d DataRace) String() string {
+	return fmt.Sprintf("data race between %s at %s and previous %s at %s",
+		strings.ToLower(d.Access.Op), d.Access.Location(), strings.ToLower(d.Previous.Op), d.Previous.Location())
+}
+
+// DataRaces returns the data races reported in the output of the tests, de-duplicated by Key.
+func (rep *TestReport) DataRaces() []DataRace {
+	races := []DataRace{}
+	for _, p := range rep.Packages {
+		for _, race := range parseDataRaces(p.Output) {
+			race.Occurrences = []RaceOccurrence{{Package: p.Package}}
+			races = addDataRace(races, race)
+		}
+
+		for _, t := range p.Tests {
+			for _, race := range parseDataRaces(t.Output) {
+				race.Occurrences = []RaceOccurrence{{Package: p.Package, Test: t.Name}}
+				races = addDataRace(races, race)
+			}
+		}
+	}
+
+	return races
+}
+
+// ParseDataRaces returns the data races reported in plain `go test` output, e.g. the output of a ghrepo.ExecError,
+// de-duplicated by Key. A race is attributed to the test reported as failed after it, or else the test running before it,
+// and to the package reported after it.
+func ParseDataRaces(output string) []DataRace {
+	lines := strings.Split(output, "\n")
+
+	races := []DataRace{}
+	for _, block := range raceBlocks(lines) {
+		race, ok := parseDataRace(lines[block[0]:block[1]])
+		if !ok {
+			continue
+		}
+
+		occ := RaceOccurrence{}
+		for _, line := range lines[:block[0]] {
+			if name, ok := strings.CutPrefix(line, "=== RUN   "); ok {
+				occ.Test = strings.TrimSpace(name)
+			} else if name, ok := strings.CutPrefix(line, "=== CONT  "); ok {
+				occ.Test = strings.TrimSpace(name)
+			}
+		}
+
+		for _, line := range lines[block[1]:] {
+			if m := reFailedTest.FindStringSubmatch(line); m != nil && occ.Test == "" {
+				occ.Test = m[1]
+			}
+
+			if m := rePackageResult.FindStringSubmatch(line); m != nil {
+				occ.Package = m[1]
+				break
+			}
+		}
+
+		race.Occurrences = []RaceOccurrence{occ}
+		races = addDataRace(races, race)
+	}
+
+	return races
+}
+
+// DataRacesOf returns the data races reported in the output of the ghrepo.ExecErrors in the error tree, if any,
+// e.g. of a failed GoTestCover run, which always enables the race detector. Races of all modules are de-duplicated.
+func DataRacesOf(err error) []DataRace {
+	races := []DataRace{}
+	for _, out := range execErrorOutputs(err) {
+		for _, race := range ParseDataRaces(out) {
+			races = addDataRace(races, race)
+		}
+	}
+
+	return races
+}
+
+// execErrorOutputs returns the outputs of the ghrepo.ExecErrors in the error tree,
+// including those joined by forEachModule.
+func execErrorOutputs(err error) []string {
+	switch err := err.(type) {
+	case ghrepo.ExecError:
+		return []string{err.Out}
+	case interface{ Unwrap() []error }:
+		outs := []string{}
+		for _, e := range err.Unwrap() {
+			outs = append(outs, execErrorOutputs(e)...)
+		}
+
+		return outs
+	case interface{ Unwrap() error }:
+		return execErrorOutputs(err.Unwrap())
+	default:
+		return nil
+	}
+}
+
+// addDataRace adds the race to the races or, if a race with the same key exists, its occurrences to that race.
+func addDataRace(races []DataRace, race DataRace) []DataRace {
+	key := race.Key()
+
+	i := slices.IndexFunc(races, func(d DataRace) bool { return d.Key() == key })
+	if i < 0 {
+		return append(races, race)
+	}
+
+	for _, occ := range race.Occurrences {
+		if !slices.Contains(races[i].Occurrences, occ) {
+			races[i].Occurrences = append(races[i].Occurrences, occ)
+		}
+	}
+
+	return races
+}
+
+// parseDataRaces parses the race reports in the lines of output.
+func parseDataRaces(lines []string) []DataRace {
+	races := []DataRace{}
+	for _, block := range raceBlocks(lines) {
+		if race, ok := parseDataRace(lines[block[0]:block[1]]); ok {
+			races = append(races, race)
+		}
+	}
+
+	return races
+}
+
+// raceBlocks returns the start and end indices of the race reports in the lines, without the separator lines.
+func raceBlocks(lines []string) [][2]int {
+	blocks := [][2]int{}
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != raceHeader {
+			continue
+		}
+
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != raceSeparator {
+			end++
+		}
+
+		blocks = append(blocks, [2]int{i + 1, end})
+		i = end
+	}
+
+	return blocks
+}
+
+// parseDataRace parses a race report: sections of a header line followed by a stack,
+// separated by empty lines. It reports false if the report has no two accesses.
+func parseDataRace(lines []string) (DataRace, bool) {
+	race := DataRace{}
+	accesses := 0
+
+	var stack *[]RaceFrame
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if m := reRaceAccess.FindStringSubmatch(line); m != nil {
+			access := &race.Access
+			if m[1] != "" {
+				access = &race.Previous
+			}
+
+			access.Op = strings.ToUpper(m[2][:1]) + m[2][1:] // "Previous write" is "Write"
+			access.Addr = m[3]
+			access.Goroutine = 1 // main
+			if m[4] != "" {
+				access.Goroutine, _ = strconv.Atoi(m[4])
+			}
+
+			stack = &access.Stack
+			accesses++
+
+			continue
+		}
+
+		if m := reRaceGoroutine.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			race.Goroutines = append(race.Goroutines, RaceGoroutine{ID: id, State: m[2]})
+			stack = &race.Goroutines[len(race.Goroutines)-1].CreatedAt
+
+			continue
+		}
+
+		if line == "" || stack == nil || i+1 >= len(lines) {
+			continue
+		}
+
+		// a frame is the function on one line and its position on the next
+		if m := reStackPosition.FindStringSubmatch(strings.TrimSpace(lines[i+1])); m != nil && strings.HasSuffix(line, ")") {
+			n, _ := strconv.Atoi(m[2])
+			*stack = append(*stack, RaceFrame{Func: stackFunc(line), File: m[1], Line: n})
+			i++
+		}
+	}
+
+	return race, accesses == 2
+}
+
+// stackFunc returns the function of a stack frame line without the argument list, e.g. "m.(*T).inc" for "m.(*T).inc()".
+func stackFunc(line string) string {
+	if i := strings.LastIndexByte(line, '('); i > 0 && strings.HasSuffix(line, ")") {
+		return line[:i]
+	}
+
+	return line
+}
@@ -0,0 +1,145 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/MarkRosemaker/ghrepo"
+)
+
+const raceOutput = `=== RUN   TestRace
+==================
+WARNING: DATA RACE
+Read at 0x00c0000182a8 by goroutine 8:
+  example.com/r.(*counter).inc()
+      /tmp/racemod/r_test.go:7 +0x36
+  example.com/r.TestRace.gowrap1()
+      /tmp/racemod/r_test.go:14 +0x17
+
+Previous write at 0x00c0000182a8 by main goroutine:
+  example.com/r.(*counter).inc()
+      /tmp/racemod/r_test.go:7 +0x4a
+  example.com/r.TestRace()
+      /tmp/racemod/r_test.go:15 +0xd0
+
+Goroutine 8 (running) created at:
+  example.com/r.TestRace()
+      /tmp/racemod/r_test.go:14 +0xc4
+  testing.tRunner()
+      /usr/local/go/src/testing/testing.go:1792 +0x225
+==================
+    testing.go:1490: race detected during execution of test
+--- FAIL: TestRace (0.00s)
+FAIL
+FAIL	example.com/r	0.018s
+`
+
+func TestParseDataRaces(t *testing.T) {
+	races := ParseDataRaces(raceOutput + strings.Replace(raceOutput, "example.com/r\t", "example.com/other\t", 1))
+	if len(races) != 1 {
+		t.Fatalf("got %d races, want 1 de-duplicated race", len(races))
+	}
+
+	race := races[0]
+	if race.Access.Op != "Read" || race.Access.Goroutine != 8 || race.Access.Addr != "0x00c0000182a8" || len(race.Access.Stack) != 2 {
+		t.Errorf("unexpected access %+v", race.Access)
+	}
+
+	if race.Previous.Op != "Write" || race.Previous.Goroutine != 1 {
+		t.Errorf("unexpected previous access %+v", race.Previous)
+	}
+
+	if want := (RaceFrame{Func: "example.com/r.(*counter).inc", File: "/tmp/racemod/r_test.go", Line: 7}); race.Access.Location() != want {
+		t.Errorf("Location() = %+v, want %+v", race.Access.Location(), want)
+	}
+
+	if len(race.Goroutines) != 1 || race.Goroutines[0].ID != 8 || race.Goroutines[0].State != "running" || len(race.Goroutines[0].CreatedAt) != 2 {
+		t.Errorf("unexpected goroutines %+v", race.Goroutines)
+	}
+
+	want := []RaceOccurrence{{Package: "example.com/r", Test: "TestRace"}, {Package: "example.com/other", Test: "TestRace"}}
+	if len(race.Occurrences) != 2 || race.Occurrences[0] != want[0] || race.Occurrences[1] != want[1] {
+		t.Errorf("Occurrences = %+v, want %+v", race.Occurrences, want)
+	}
+
+	if got := race.String(); got != "data race between read at example.com/r.(*counter).inc (r_test.go:7) and previous write at example.com/r.(*counter).inc (r_test.go:7)" {
+		t.Errorf("String() = %q", got)
+	}
+
+	// without -v, the test is taken from the failure line
+	races = ParseDataRaces(strings.TrimPrefix(raceOutput, "=== RUN   TestRace\n"))
+	if len(races) != 1 || races[0].Occurrences[0].Test != "TestRace" {
+		t.Errorf("unexpected races %+v", races)
+	}
+
+	if races := ParseDataRaces("ok  \texample.com/r\t0.01s\n"); len(races) != 0 {
+		t.Errorf("unexpected races %+v", races)
+	}
+}
+
+func TestDataRace_Key(t *testing.T) {
+	a := RaceAccess{Op: "Write", Stack: []RaceFrame{{Func: "m.Set", File: "/a/m.go", Line: 3}}}
+	b := RaceAccess{Op: "Read", Stack: []RaceFrame{{Func: "m.Get", File: "/b/m.go", Line: 5}}}
+
+	if (DataRace{Access: a, Previous: b}).Key() != (DataRace{Access: b, Previous: a}).Key() {
+		t.Error("key depends on the order of the accesses")
+	}
+
+	c := b
+	c.Stack = []RaceFrame{{Func: "m.Get", File: "/b/m.go", Line: 6}}
+
+	if (DataRace{Access: a, Previous: b}).Key() == (DataRace{Access: a, Previous: c}).Key() {
+		t.Error("races at different lines have the same key")
+	}
+}
+
+func TestDataRacesOf(t *testing.T) {
+	m := GoModule{Dir: ".", Path: "example.com/r"}
+	err := errors.Join(
+		ModuleError{Module: m, Err: ghrepo.ExecError{Cmd: "go test", Out: raceOutput, Err: errors.New("exit status 1")}},
+		ModuleError{Module: m, Err: errors.New("other")},
+	)
+
+	if races := DataRacesOf(err); len(races) != 1 {
+		t.Errorf("got %d races, want 1", len(races))
+	}
+
+	if races := DataRacesOf(errors.New("other")); len(races) != 0 {
+		t.Errorf("unexpected races %+v", races)
+	}
+}
+
+func TestTestReport_DataRaces(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":      "module example.com/m\n\ngo 1.24\n",
+		"c/c.go":      "package c\n\nvar n int\n\nfunc Set() { n = 1 }\n\nfunc Get() int { return n }\n\nfunc Race() {\n\tdone := make(chan bool)\n\tgo func() { Set(); done <- true }()\n\tGet()\n\t<-done\n}\n",
+		"a/a_test.go": "package a\n\nimport (\n\t\"testing\"\n\n\t\"example.com/m/c\"\n)\n\nfunc TestA(t *testing.T) { c.Race() }\n",
+		"b/b_test.go": "package b\n\nimport (\n\t\"testing\"\n\n\t\"example.com/m/c\"\n)\n\nfunc TestOK(t *testing.T) {}\n\nfunc TestB(t *testing.T) { c.Race() }\n",
+	})
+
+	report, err := repo.Test(context.Background(), TestOptions{Race: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	races := report.DataRaces()
+	if len(races) != 1 {
+		t.Fatalf("got %d races, want 1: %+v", len(races), races)
+	}
+
+	want := []RaceOccurrence{{Package: "example.com/m/a", Test: "TestA"}, {Package: "example.com/m/b", Test: "TestB"}}
+	if occ := races[0].Occurrences; len(occ) != 2 || occ[0] != want[0] || occ[1] != want[1] {
+		t.Errorf("Occurrences = %+v, want %+v", occ, want)
+	}
+
+	for _, access := range []RaceAccess{races[0].Access, races[0].Previous} {
+		if loc := access.Location(); !strings.HasSuffix(loc.File, "/c/c.go") || !strings.HasPrefix(loc.Func, "example.com/m/c.") {
+			t.Errorf("unexpected location %+v", loc)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package gorepo
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
 	"path/filepath"
+	"slices"
 
 	"github.com/MarkRosemaker/ghrepo"
 	"github.com/spf13/afero"
@@ -14,14 +16,14 @@ import (
 // Repository represents a local go repository.
 type Repository struct{ *ghrepo.Repository }
 
+// IsGoRepo reports whether the repository contains at least one Go module.
 func (r Repository) IsGoRepo() (bool, error) {
-	if _, err := r.Stat("go.mod"); err == nil {
-		return true, nil
-	} else if errors.Is(err, fs.ErrNotExist) {
-		return false, nil
-	} else {
+	mods, err := r.GoModules()
+	if err != nil {
 		return false, err
 	}
+
+	return len(mods) > 0, nil
 }
 
 // GoModInit initializes a go module in the repository.
@@ -43,18 +45,17 @@ func (r Repository) UpdateDependencies(ctx context.Context) error {
 	return r.GoModVendor(ctx)
 }
 
-// GoGetAll updates all package dependencies reachable from the module.
+// GoGetAll updates all package dependencies reachable from each module.
 func (r Repository) GoGetAll(ctx context.Context) error {
 	// NOTE:
 	// 	./... = only packages reachable from the module + their real dependencies
 	// all = every module ever mentioned in the build list, including ones that only exist for old versions
-	_, err := r.ExecCommand(ctx, "go", "get", "-u", "./...")
-	return err
+	return r.goEachModuleEnv(ctx, moduleModeEnv, "get", "-u", "./...")
 }
 
-// UpdateTools updates all go tools in the repository.
+// UpdateTools updates all go tools in the repository, independently of the other dependencies.
 func (r Repository) UpdateTools(ctx context.Context) error {
-	if err := r.GoGetAll(ctx); err != nil {
+	if err := r.GoGetTools(ctx); err != nil {
 		return err
 	}
 
@@ -65,25 +66,76 @@ func (r Repository) UpdateTools(ctx context.Context) error {
 	return r.GoModVendor(ctx)
 }
 
-// GoGetTools updates all tool dependencies listed in go.mod.
+// GoGetTools updates all tool dependencies listed in the go.mod files.
 func (r Repository) GoGetTools(ctx context.Context) error {
-	_, err := r.ExecCommand(ctx, "go", "get", "-u", "tool")
-	return err
+	return r.goEachModuleEnv(ctx, moduleModeEnv, "get", "-u", "tool")
 }
 
-// GoModTidy tidies the go module in the repository.
+// GoModTidy tidies every go module in the repository.
 func (r Repository) GoModTidy(ctx context.Context) error {
-	_, err := r.ExecCommand(ctx, "go", "mod", "tidy")
-	return err
+	return r.goEachModule(ctx, "mod", "tidy")
 }
 
-// GoModVendor vendors all dependencies in the repository.
+// GoModVendor vendors the dependencies of every go module in the repository.
+// If the repository has a go.work file, the dependencies of the workspace are vendored
+// with `go work vendor` instead, since `go mod vendor` cannot be run in workspace mode.
 func (r Repository) GoModVendor(ctx context.Context) error {
-	_, err := r.ExecCommand(ctx, "go", "mod", "vendor")
+	ok, err := r.hasWorkspace()
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		_, err := r.execIn(ctx, ".", "go", "work", "vendor")
+		return err
+	}
+
+	return r.goEachModuleEnv(ctx, moduleModeEnv, "mod", "vendor")
+}
+
+// revendor vendors the dependencies again after the go.mod of the module changed, if they are vendored:
+// those of the workspace if the repository has a go.work file, otherwise those of the module.
+func (r Repository) revendor(ctx context.Context, m GoModule) error {
+	ok, err := r.hasWorkspace()
+	if err != nil {
+		return err
+	}
+
+	dir, env, args := m.Dir, moduleModeEnv, []string{"mod", "vendor"}
+	if ok {
+		dir, env, args = ".", nil, []string{"work", "vendor"}
+	}
+
+	if _, err := r.Stat(filepath.Join(dir, "vendor")); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	_, err = r.execEnvIn(ctx, dir, env, "go", args...)
+
 	return err
 }
 
+// Goimports runs goimports on every go file of the repository,
+// using the version declared as a tool in the go.mod of the file's module, if any.
 func (r Repository) Goimports(ctx context.Context) error {
+	mods, err := r.GoModules()
+	if err != nil {
+		return err
+	}
+
+	// resolve the command of every module once, including the root in case it is no module
+	commands := make(map[string][]string, len(mods)+1)
+	for _, m := range append([]GoModule{{Dir: "."}}, mods...) {
+		name, args, err := r.toolCommand(m, "goimports")
+		if err != nil {
+			return err
+		}
+
+		commands[m.Dir] = append([]string{name}, args...)
+	}
+
 	eg := errgroup.Group{}
 
 	if err := afero.Walk(r, ".", func(path string, info fs.FileInfo, err error) error {
@@ -106,9 +158,17 @@ func (r Repository) Goimports(ctx context.Context) error {
 			return nil
 		}
 
+		m := moduleOf(mods, path)
+		cmd := commands[m.Dir]
+
+		rel, err := filepath.Rel(m.Dir, path)
+		if err != nil {
+			return err
+		}
+
 		// Run goimports -w on this single file
 		eg.Go(func() error {
-			_, err := r.ExecCommand(ctx, "goimports", "-w", path)
+			_, err := r.execIn(ctx, m.Dir, cmd[0], slices.Concat(cmd[1:], []string{"-w", rel})...)
 			return err
 		})
 
@@ -120,22 +180,86 @@ func (r Repository) Goimports(ctx context.Context) error {
 	return eg.Wait()
 }
 
+// Gofumpt runs gofumpt on every module of the repository,
+// using the version declared as a tool in its go.mod, if any.
+// Each run only formats the files of its module, so nested modules are formatted by their own version.
 func (r Repository) Gofumpt(ctx context.Context) error {
-	_, err := r.ExecCommand(ctx, "gofumpt", "-extra", "-w", ".")
-	return err
-}
+	return r.forEachModule(func(m GoModule) error {
+		files, err := r.moduleGoFiles(m)
+		if err != nil || len(files) == 0 {
+			return err
+		}
 
-func (r Repository) GoFix(ctx context.Context) error {
-	if _, err := r.ExecCommand(ctx, "go", "fix", "./..."); err != nil {
+		name, args, err := r.toolCommand(m, "gofumpt")
+		if err != nil {
+			return err
+		}
+
+		_, err = r.execIn(ctx, m.Dir, name, slices.Concat(args, []string{"-extra", "-w"}, files)...)
 		return err
+	})
+}
+
+// moduleGoFiles returns the Go files of the module relative to its directory, sorted,
+// leaving out nested modules and the directories the go command ignores, like vendor and testdata.
+func (r Repository) moduleGoFiles(m GoModule) ([]string, error) {
+	files := []string{}
+	if err := afero.Walk(r, m.Dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == m.Dir {
+				return nil
+			}
+
+			if skipDir(info.Name()) {
+				return filepath.SkipDir
+			}
+
+			if _, err := r.Stat(filepath.Join(path, goModFile)); err == nil {
+				return filepath.SkipDir // nested module
+			}
+
+			return nil
+		}
+
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(m.Dir, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, filepath.ToSlash(rel))
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("listing Go files of module %s: %w", m.Path, err)
 	}
 
-	return nil
+	return files, nil
+}
+
+func (r Repository) GoFix(ctx context.Context) error {
+	return r.goEachModule(ctx, "fix", "./...")
 }
 
-// GoVet runs go vet on the repository
+// GoVet runs go vet on every module of the repository
 func (r Repository) GoVet(ctx context.Context) error {
-	if _, err := r.ExecCommand(ctx, "go", "vet", "./..."); err != nil {
+	return r.forEachModule(func(m GoModule) error { return r.goVet(ctx, m) })
+}
+
+// goVet runs go vet on the packages of the module, all of them if none are given.
+func (r Repository) goVet(ctx context.Context, m GoModule, pkgs ...string) error {
+	if len(pkgs) == 0 {
+		pkgs = []string{"./..."}
+	}
+
+	if _, err := r.execIn(ctx, m.Dir, "go", append([]string{"vet"}, pkgs...)...); err != nil {
 		const noPackagesMsg = "go: warning: \"./...\" matched no packages\nno packages to vet"
 		if execErr := (ghrepo.ExecError{}); errors.As(err, &execErr) &&
 			execErr.Out == noPackagesMsg {
@@ -149,6 +273,18 @@ func (r Repository) GoVet(ctx context.Context) error {
 }
 
 func (r Repository) GoGenerate(ctx context.Context) error {
-	_, err := r.ExecCommand(ctx, "go", "generate", "./...")
-	return err
+	return r.goEachModule(ctx, "generate", "./...")
+}
+
+// goEachModule runs the go command with the given arguments in the directory of every module.
+func (r Repository) goEachModule(ctx context.Context, args ...string) error {
+	return r.goEachModuleEnv(ctx, nil, args...)
+}
+
+// goEachModuleEnv is like goEachModule, but adds env to the environment of the go command.
+func (r Repository) goEachModuleEnv(ctx context.Context, env []string, args ...string) error {
+	return r.forEachModule(func(m GoModule) error {
+		_, err := r.execEnvIn(ctx, m.Dir, env, "go", args...)
+		return err
+	})
 }
@@ -40,3 +40,31 @@ func TestGoVet_NoPackages(t *testing.T) {
 		t.Errorf("unexpected error for repo with no Go files: %v", err)
 	}
 }
+
+func TestUpdateDependencies_Workspace(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.work":  "go 1.24\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.24\n",
+		"a/a.go":   "package a\n\nfunc A() {}\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.24\n",
+		"b/b.go":   "package b\n\nfunc B() {}\n",
+	})
+
+	if err := repo.UpdateDependencies(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the workspace is vendored as a whole
+	if _, err := repo.Stat("vendor/modules.txt"); err != nil {
+		t.Errorf("workspace was not vendored: %v", err)
+	}
+
+	for _, dir := range []string{"a/vendor", "b/vendor"} {
+		if _, err := repo.Stat(dir); err == nil {
+			t.Errorf("module %s was vendored in workspace mode", dir)
+		}
+	}
+}
@@ -0,0 +1,360 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MarkRosemaker/ghrepo"
+)
+
+// TestStatus is the outcome of a test or package.
+type TestStatus string
+
+const (
+	TestPass TestStatus = "pass"
+	TestFail TestStatus = "fail"
+	TestSkip TestStatus = "skip"
+)
+
+// TestOptions configures how tests are run by Test and GoTestCoverWithOptions.
+// The zero value runs the tests of all packages like a plain `go test ./...`.
+type TestOptions struct {
+	// Packages are the package patterns to test in every module, "./..." if empty.
+	Packages []string
+	// Tags are the build tags to consider satisfied.
+	Tags []string
+	// Race enables the race detector.
+	Race bool
+	// Run only runs the tests, examples and fuzz tests matching the regular expression.
+	Run string
+	// Skip skips the tests, examples and fuzz tests matching the regular expression.
+	Skip string
+	// Count runs each test that many times. Any count, including 1, bypasses the test cache.
+	Count int
+	// Timeout makes a test binary panic if it runs longer. Zero keeps the default of the go command.
+	Timeout time.Duration
+	// CPU runs the tests once for every listed GOMAXPROCS value.
+	CPU []int
+	// Env are additional environment variables, as "key=value" pairs.
+	Env []string
+	// Shuffle randomizes the execution order of tests and benchmarks.
+	Shuffle bool
+	// Seed is the seed for shuffling, e.g. to replay the order of a failed run.
+	// If zero and Shuffle is set, a seed is chosen and reported in the result.
+	Seed int64
+}
+
+// shuffleSeed returns the seed to shuffle the tests with, or zero if they are not shuffled.
+func (o TestOptions) shuffleSeed() int64 {
+	if o.Seed != 0 || !o.Shuffle {
+		return o.Seed
+	}
+
+	return time.Now().UnixNano() // what -shuffle=on uses
+}
+
+// args returns the flags for `go test`, using the given shuffle seed, followed by the packages.
+func (o TestOptions) args(seed int64) []string {
+	args := []string{}
+	if len(o.Tags) > 0 {
+		args = append(args, "-tags="+strings.Join(o.Tags, ","))
+	}
+
+	if o.Race {
+		args = append(args, "-race")
+	}
+
+	if o.Run != "" {
+		args = append(args, "-run="+o.Run)
+	}
+
+	if o.Skip != "" {
+		args = append(args, "-skip="+o.Skip)
+	}
+
+	if o.Count > 0 {
+		args = append(args, "-count="+strconv.Itoa(o.Count))
+	}
+
+	if o.Timeout > 0 {
+		args = append(args, "-timeout="+o.Timeout.String())
+	}
+
+	if len(o.CPU) > 0 {
+		cpus := make([]string, len(o.CPU))
+		for i, n := range o.CPU {
+			cpus[i] = strconv.Itoa(n)
+		}
+
+		args = append(args, "-cpu="+strings.Join(cpus, ","))
+	}
+
+	if seed != 0 {
+		args = append(args, "-shuffle="+strconv.FormatInt(seed, 10))
+	}
+
+	if len(o.Packages) == 0 {
+		return append(args, "./...")
+	}
+
+	return append(args, o.Packages...)
+}
+
+// TestReport is the result of running the tests of the repository.
+type TestReport struct {
+	// Seed is the seed the tests were shuffled with, or zero if they were not shuffled.
+	Seed int64
+	// Packages are the results per package, in the order they were first reported.
+	Packages []PackageResult
+}
+
+// PackageResult is the result of the tests of a single package.
+type PackageResult struct {
+	// Module is the module of the repository the package belongs to.
+	Module GoModule
+	// Package is the import path of the package.
+	Package string
+	// Status is the outcome of the package. Packages without test files are skipped.
+	Status TestStatus
+	// Elapsed is the time the tests of the package took.
+	Elapsed time.Duration
+	// Output is the output of the package that does not belong to a single test, e.g. the final "ok" or "FAIL" line.
+	Output []string
+	// BuildFailed is true if the package or its test binary could not be built.
+	BuildFailed bool
+	// BuildOutput is the output of the failed build, i.e. the compiler errors.
+	BuildOutput []string
+	// Tests are the results of the tests, benchmarks, fuzz tests and examples of the package,
+	// including subtests, in the order they were started.
+	Tests []TestResult
+}
+
+// TestResult is the result of a single test.
+type TestResult struct {
+	// Package is the import path of the package of the test.
+	Package string
+	// Name is the name of the test, e.g. "TestFoo" or "TestFoo/subtest".
+	Name string
+	// Status is the outcome of the test. Tests that were interrupted, e.g. by a panic or a timeout, fail.
+	Status TestStatus
+	// Elapsed is the time the test took.
+	Elapsed time.Duration
+	// Output is the output of the test, including the "=== RUN" and "--- FAIL" lines.
+	Output []string
+	// Panicked is true if the test panicked.
+	Panicked bool
+}
+
+// testEvent is an event of the JSON stream printed by `go test -json`, see `go doc test2json`.
+type testEvent struct {
+	Action      string
+	Package     string
+	ImportPath  string // of build events
+	Test        string
+	Elapsed     float64 // seconds
+	Output      string
+	FailedBuild string
+}
+
+// Test runs the tests of every module of the repository with `go test -json` and returns the results
+// per package and test. Failing tests do not cause an error: use the Failed methods of the report.
+// An error is returned if the go command fails for another reason.
+func (r Repository) Test(ctx context.Context, opts TestOptions) (*TestReport, error) {
+	seed := opts.shuffleSeed()
+
+	report := &TestReport{Seed: seed}
+	if err := r.forEachModule(func(m GoModule) error {
+		found, err := r.testModule(ctx, m, opts, seed)
+		if found != nil {
+			report.Packages = append(report.Packages, found.Packages...)
+		}
+
+		return err
+	}); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+// testModule runs the tests of a single module with `go test -json`, shuffled with the given seed.
+func (r Repository) testModule(ctx context.Context, m GoModule, opts TestOptions, seed int64) (*TestReport, error) {
+	out, err := r.outputEnvIn(ctx, m.Dir, opts.Env, "go", append([]string{"test", "-json"}, opts.args(seed)...)...)
+
+	found, parseErr := parseTestEvents(m, out)
+	if parseErr != nil {
+		return nil, errors.Join(err, parseErr)
+	}
+
+	found.Seed = seed
+
+	if err != nil && !found.Failed() {
+		if execErr := (ghrepo.ExecError{}); errors.As(err, &execErr) &&
+			execErr.Out == noTestPackagesMsg {
+			return found, nil
+		}
+
+		return found, err
+	}
+
+	return found, nil
+}
+
+// parseTestEvents parses the output of `go test -json`.
+func parseTestEvents(m GoModule, out []byte) (*TestReport, error) {
+	events, err := decodeJSONStream[testEvent](out)
+	if err != nil {
+		return nil, fmt.Errorf("parsing go test output: %w", err)
+	}
+
+	report := &TestReport{}
+	pkgs := map[string]*PackageResult{}
+	order := []string{}
+	buildOutput := map[string][]string{}
+
+	pkg := func(path string) *PackageResult {
+		if p, ok := pkgs[path]; ok {
+			return p
+		}
+
+		p := &PackageResult{Module: m, Package: path}
+		pkgs[path] = p
+		order = append(order, path)
+
+		return p
+	}
+
+	for _, e := range events {
+		switch e.Action {
+		case "build-output":
+			buildOutput[e.ImportPath] = append(buildOutput[e.ImportPath], strings.TrimSuffix(e.Output, "\n"))
+			continue
+		case "build-fail":
+			continue
+		}
+
+		if e.Package == "" {
+			continue
+		}
+
+		p := pkg(e.Package)
+
+		if e.Test == "" {
+			switch e.Action {
+			case "output":
+				p.Output = append(p.Output, strings.TrimSuffix(e.Output, "\n"))
+			case "pass", "fail", "skip":
+				p.Status = TestStatus(e.Action)
+				p.Elapsed = seconds(e.Elapsed)
+
+				if e.FailedBuild != "" {
+					p.BuildFailed = true
+					p.BuildOutput = buildOutput[e.FailedBuild]
+				}
+			}
+
+			continue
+		}
+
+		i := slices.IndexFunc(p.Tests, func(t TestResult) bool { return t.Name == e.Test })
+		if i < 0 {
+			p.Tests = append(p.Tests, TestResult{Package: e.Package, Name: e.Test})
+			i = len(p.Tests) - 1
+		}
+
+		t := &p.Tests[i]
+
+		switch e.Action {
+		case "output":
+			line := strings.TrimSuffix(e.Output, "\n")
+			t.Output = append(t.Output, line)
+
+			if strings.HasPrefix(line, "panic: ") {
+				t.Panicked = true
+			}
+		case "pass", "fail", "skip":
+			t.Status = TestStatus(e.Action)
+			t.Elapsed = seconds(e.Elapsed)
+		}
+	}
+
+	for _, path := range order {
+		p := pkgs[path]
+		if p.Status == "" {
+			p.Status = TestFail // e.g. killed
+		}
+
+		for i := range p.Tests {
+			if p.Tests[i].Status == "" {
+				p.Tests[i].Status = TestFail // interrupted
+			}
+		}
+
+		report.Packages = append(report.Packages, *p)
+	}
+
+	return report, nil
+}
+
+func seconds(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
+
+// Failed reports whether any package failed, including packages that could not be built.
+func (rep *TestReport) Failed() bool {
+	return slices.ContainsFunc(rep.Packages, func(p PackageResult) bool { return p.Status == TestFail })
+}
+
+// FailedPackages returns the packages that failed, including those that could not be built.
+func (rep *TestReport) FailedPackages() []PackageResult {
+	failed := []PackageResult{}
+	for _, p := range rep.Packages {
+		if p.Status == TestFail {
+			failed = append(failed, p)
+		}
+	}
+
+	return failed
+}
+
+// FailedTests returns the tests that failed, including subtests and the parents of failed subtests.
+func (rep *TestReport) FailedTests() []TestResult {
+	failed := []TestResult{}
+	for _, p := range rep.Packages {
+		for _, t := range p.Tests {
+			if t.Status == TestFail {
+				failed = append(failed, t)
+			}
+		}
+	}
+
+	return failed
+}
+
+// Package returns the result of the package with the import path.
+func (rep *TestReport) Package(path string) (PackageResult, bool) {
+	i := slices.IndexFunc(rep.Packages, func(p PackageResult) bool { return p.Package == path })
+	if i < 0 {
+		return PackageResult{}, false
+	}
+
+	return rep.Packages[i], true
+}
+
+// Test returns the result of the named test in the package with the import path.
+func (rep *TestReport) Test(pkg, name string) (TestResult, bool) {
+	p, ok := rep.Package(pkg)
+	if !ok {
+		return TestResult{}, false
+	}
+
+	i := slices.IndexFunc(p.Tests, func(t TestResult) bool { return t.Name == name })
+	if i < 0 {
+		return TestResult{}, false
+	}
+
+	return p.Tests[i], true
+}
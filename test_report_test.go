@@ -0,0 +1,147 @@
+package gorepo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTest(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n",
+		"ok/ok_test.go": `package ok
+
+import "testing"
+
+func TestOK(t *testing.T) {}
+
+func TestSkip(t *testing.T) { t.Skip("not today") }
+`,
+		"fail/fail_test.go": `package fail
+
+import "testing"
+
+func TestParent(t *testing.T) {
+	t.Run("good", func(t *testing.T) {})
+	t.Run("bad", func(t *testing.T) { t.Error("broken") })
+}
+`,
+		"panic/panic_test.go": `package panic
+
+import "testing"
+
+func TestPanic(t *testing.T) { panic("boom") }
+`,
+		"build/build_test.go": `package build
+
+import "testing"
+
+func TestBuild(t *testing.T) { undefined() }
+`,
+		"notests/notests.go": "package notests\n",
+	})
+
+	report, err := repo.Test(context.Background(), TestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.Failed() {
+		t.Error("expected report to fail")
+	}
+
+	for pkg, want := range map[string]TestStatus{
+		"example.com/m/ok":      TestPass,
+		"example.com/m/fail":    TestFail,
+		"example.com/m/panic":   TestFail,
+		"example.com/m/build":   TestFail,
+		"example.com/m/notests": TestSkip,
+	} {
+		if p, ok := report.Package(pkg); !ok {
+			t.Errorf("package %s not in report", pkg)
+		} else if p.Status != want {
+			t.Errorf("package %s: status %q, want %q", pkg, p.Status, want)
+		}
+	}
+
+	if p, _ := report.Package("example.com/m/build"); !p.BuildFailed ||
+		!slices.ContainsFunc(p.BuildOutput, func(line string) bool { return strings.Contains(line, "undefined: undefined") }) {
+		t.Errorf("unexpected build result: failed %v, output %q", p.BuildFailed, p.BuildOutput)
+	}
+
+	if tr, ok := report.Test("example.com/m/ok", "TestSkip"); !ok || tr.Status != TestSkip {
+		t.Errorf("TestSkip: %+v", tr)
+	}
+
+	if tr, ok := report.Test("example.com/m/panic", "TestPanic"); !ok || tr.Status != TestFail || !tr.Panicked {
+		t.Errorf("TestPanic: %+v", tr)
+	}
+
+	if tr, ok := report.Test("example.com/m/fail", "TestParent/bad"); !ok ||
+		!slices.ContainsFunc(tr.Output, func(line string) bool { return strings.Contains(line, "broken") }) {
+		t.Errorf("TestParent/bad: %+v", tr)
+	}
+
+	failed := []string{}
+	for _, tr := range report.FailedTests() {
+		failed = append(failed, tr.Name)
+	}
+
+	slices.Sort(failed)
+
+	if want := []string{"TestPanic", "TestParent", "TestParent/bad"}; !slices.Equal(failed, want) {
+		t.Errorf("FailedTests() = %v, want %v", failed, want)
+	}
+
+	if n := len(report.FailedPackages()); n != 3 {
+		t.Errorf("got %d failed packages, want 3", n)
+	}
+}
+
+func TestTest_NoPackages(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{"go.mod": "module example.com/m\n\ngo 1.24\n"})
+
+	report, err := repo.Test(context.Background(), TestOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Packages) != 0 || report.Failed() {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestTestOptions_Args(t *testing.T) {
+	opts := TestOptions{
+		Packages: []string{"./a", "./b"},
+		Tags:     []string{"integration", "linux"},
+		Race:     true,
+		Run:      "TestA",
+		Skip:     "TestB",
+		Count:    3,
+		Timeout:  90 * time.Second,
+		CPU:      []int{1, 4},
+		Seed:     42,
+	}
+
+	want := "-tags=integration,linux -race -run=TestA -skip=TestB -count=3 -timeout=1m30s -cpu=1,4 -shuffle=42 ./a ./b"
+	if got := strings.Join(opts.args(opts.shuffleSeed()), " "); got != want {
+		t.Errorf("args() = %q, want %q", got, want)
+	}
+
+	if got := strings.Join(TestOptions{}.args(0), " "); got != "./..." {
+		t.Errorf("args() = %q, want %q", got, "./...")
+	}
+
+	if seed := (TestOptions{Shuffle: true}).shuffleSeed(); seed == 0 {
+		t.Error("no seed chosen")
+	}
+}
@@ -0,0 +1,156 @@
+package gorepo
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Tool is a tool declared with a tool directive in the go.mod of a module of the repository.
+type Tool struct {
+	// Module is the module of the repository that declares the tool.
+	Module GoModule
+	// Package is the import path of the main package of the tool, e.g. "golang.org/x/tools/cmd/goimports".
+	Package string
+	// Path is the path of the module providing the tool.
+	Path string
+	// Version is the required version of the module providing the tool,
+	// or empty if the tool is provided by the declaring module itself.
+	Version string
+}
+
+// Name returns the name `go tool` accepts for the tool, i.e. the last element of its package path.
+func (t Tool) Name() string { return toolName(t.Package) }
+
+// Tools returns the tools declared in the go.mod of every module of the repository,
+// each resolved to the required module that provides it.
+func (r Repository) Tools() ([]Tool, error) {
+	tools := []Tool{}
+	if err := r.forEachModule(func(m GoModule) error {
+		f, err := r.modFile(m.Dir)
+		if err != nil {
+			return err
+		}
+
+		tools = append(tools, moduleTools(m, f)...)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return tools, nil
+}
+
+// moduleTools resolves the tool directives of the go.mod
+// to the required module with the longest path that is a prefix of the package path.
+func moduleTools(m GoModule, f *modfile.File) []Tool {
+	tools := make([]Tool, 0, len(f.Tool))
+	for _, t := range f.Tool {
+		tool := Tool{Module: m, Package: t.Path}
+
+		if f.Module != nil && hasPathPrefix(t.Path, f.Module.Mod.Path) {
+			tool.Path = f.Module.Mod.Path
+		}
+
+		for _, req := range f.Require {
+			if hasPathPrefix(t.Path, req.Mod.Path) && len(req.Mod.Path) > len(tool.Path) {
+				tool.Path, tool.Version = req.Mod.Path, req.Mod.Version
+			}
+		}
+
+		tools = append(tools, tool)
+	}
+
+	return tools
+}
+
+// hasPathPrefix reports whether the package path is in the module path, i.e. equal to it or below it.
+func hasPathPrefix(pkg, mod string) bool {
+	return pkg == mod || strings.HasPrefix(pkg, mod+"/")
+}
+
+// toolName returns the name of the binary built from the package path.
+// A trailing major version suffix like "/v2" is skipped, as `go build` does.
+func toolName(pkg string) string {
+	name := path.Base(pkg)
+	if dir := path.Dir(pkg); dir != "." && isMajorVersionSuffix(name) {
+		return path.Base(dir)
+	}
+
+	return name
+}
+
+func isMajorVersionSuffix(elem string) bool {
+	n, ok := strings.CutPrefix(elem, "v")
+	return ok && n != "" && strings.Trim(n, "0123456789") == ""
+}
+
+// AddTool adds a tool directive for the package to the go.mod at the root of the repository
+// and requires the module providing it. The package may carry a version query, e.g. "mvdan.cc/gofumpt@v0.8.0".
+// The module is re-vendored if it has a vendor directory.
+// Like the other go.mod edits, it only changes the root module, not every module of the repository:
+// the tools of nested modules are left as they are.
+func (r Repository) AddTool(ctx context.Context, pkg string) error {
+	return r.getTool(ctx, pkg)
+}
+
+// RemoveTool removes the tool directive for the package from the go.mod at the root of the repository,
+// along with the requirement of the module providing it if nothing else needs it.
+// The module is re-vendored if it has a vendor directory.
+// Like AddTool, it only changes the root module.
+func (r Repository) RemoveTool(ctx context.Context, pkg string) error {
+	pkg, _, _ = strings.Cut(pkg, "@")
+	return r.getTool(ctx, pkg+"@none")
+}
+
+func (r Repository) getTool(ctx context.Context, pkg string) error {
+	if _, err := r.execEnvIn(ctx, ".", moduleModeEnv, "go", "get", "-tool", pkg); err != nil {
+		return err
+	}
+
+	return r.revendor(ctx, GoModule{Dir: "."})
+}
+
+// toolCommand returns the command that runs the named tool in the module:
+// `go tool <package>` if the tool is declared in its go.mod, so that the pinned version is used,
+// otherwise the binary of that name on PATH.
+func (r Repository) toolCommand(m GoModule, name string) (string, []string, error) {
+	f, err := r.modFile(m.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return name, nil, nil
+	} else if err != nil {
+		return "", nil, err
+	}
+
+	for _, t := range f.Tool {
+		if toolName(t.Path) == name {
+			return "go", []string{"tool", t.Path}, nil
+		}
+	}
+
+	return name, nil, nil
+}
+
+// moduleOf returns the innermost module containing the file, or the repository root if no module does.
+func moduleOf(mods []GoModule, name string) GoModule {
+	owner := GoModule{Dir: "."}
+	for _, m := range mods {
+		switch {
+		case m.Dir == ".":
+			if owner.Dir == "." {
+				owner = m
+			}
+		case hasPathPrefix(filepath.ToSlash(name), filepath.ToSlash(m.Dir)) &&
+			(owner.Dir == "." || len(m.Dir) > len(owner.Dir)):
+			owner = m
+		}
+	}
+
+	return owner
+}
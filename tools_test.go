@@ -0,0 +1,232 @@
+package gorepo
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestToolName(t *testing.T) {
+	for _, tc := range []struct {
+		pkg, want string
+	}{
+		{"golang.org/x/tools/cmd/goimports", "goimports"},
+		{"mvdan.cc/gofumpt", "gofumpt"},
+		{"github.com/golangci/golangci-lint/v2/cmd/golangci-lint", "golangci-lint"},
+		{"example.com/tool/v2", "tool"},
+		{"v2", "v2"},
+	} {
+		if got := toolName(tc.pkg); got != tc.want {
+			t.Errorf("toolName(%q) = %q, want %q", tc.pkg, got, tc.want)
+		}
+	}
+}
+
+func TestTools(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": `module example.com/m
+
+go 1.24
+
+tool (
+	example.com/m/cmd/gen
+	golang.org/x/tools/cmd/goimports
+)
+
+require (
+	golang.org/x/tools v0.30.0
+	golang.org/x/tools/gopls v0.18.0
+)
+`,
+		"sub/go.mod": `module example.com/m/sub
+
+go 1.24
+
+tool mvdan.cc/gofumpt
+
+require mvdan.cc/gofumpt v0.8.0
+`,
+	})
+
+	tools, err := repo.Tools()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Tool{
+		{Package: "example.com/m/cmd/gen", Path: "example.com/m"},
+		{Package: "golang.org/x/tools/cmd/goimports", Path: "golang.org/x/tools", Version: "v0.30.0"},
+		{Package: "mvdan.cc/gofumpt", Path: "mvdan.cc/gofumpt", Version: "v0.8.0"},
+	}
+
+	if len(tools) != len(want) {
+		t.Fatalf("got %d tools, want %d: %v", len(tools), len(want), tools)
+	}
+
+	for i, w := range want {
+		got := tools[i]
+		if got.Package != w.Package || got.Path != w.Path || got.Version != w.Version {
+			t.Errorf("Tools()[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+
+	if tools[2].Module.Dir != "sub" || tools[2].Name() != "gofumpt" {
+		t.Errorf("unexpected tool %+v", tools[2])
+	}
+}
+
+func TestAddTool_Workspace(t *testing.T) {
+	unvendoredTestModule(t)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.work":         "go 1.24\n\nuse (\n\t.\n\t./sub\n)\n",
+		"go.mod":          "module example.com/m\n\ngo 1.24\n",
+		"cmd/gen/main.go": "package main\n\nfunc main() {}\n",
+		"sub/go.mod":      "module example.com/m/sub\n\ngo 1.24\n",
+	})
+
+	// the workspace is vendored, so adding the tool vendors it again
+	if err := repo.GoModVendor(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.AddTool(context.Background(), "example.com/m/cmd/gen"); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := repo.Tools()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tools) != 1 || tools[0].Package != "example.com/m/cmd/gen" || tools[0].Module.Dir != "." {
+		t.Errorf("unexpected tools %+v", tools)
+	}
+}
+
+func TestModuleOf(t *testing.T) {
+	mods := []GoModule{{Dir: ".", Path: "example.com/m"}, {Dir: "a", Path: "example.com/m/a"}, {Dir: "a/b", Path: "example.com/m/a/b"}}
+
+	for _, tc := range []struct {
+		name, want string
+	}{
+		{"main.go", "."},
+		{"a/a.go", "a"},
+		{"ab/ab.go", "."},
+		{"a/b/c/c.go", "a/b"},
+	} {
+		if got := moduleOf(mods, tc.name); got.Dir != tc.want {
+			t.Errorf("moduleOf(%q) = %q, want %q", tc.name, got.Dir, tc.want)
+		}
+	}
+
+	if got := moduleOf(nil, "main.go"); got.Dir != "." {
+		t.Errorf("moduleOf(nil) = %q, want %q", got.Dir, ".")
+	}
+}
+
+func TestGofumpt_DeclaredTool(t *testing.T) {
+	// a stand-in for gofumpt that records its arguments
+	const standIn = `package main
+
+import (
+	"os"
+	"strings"
+)
+
+func main() {
+	if err := os.WriteFile("args.txt", []byte(strings.Join(os.Args[1:], " ")), 0o644); err != nil {
+		panic(err)
+	}
+}
+`
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":              "module example.com/m\n\ngo 1.24\n\ntool example.com/m/cmd/gofumpt\n",
+		"m.go":                "package m\n",
+		"cmd/gofumpt/main.go": standIn,
+		"testdata/bad.go":     "package bad\n",
+		// the files of a nested module are formatted by the version declared there
+		"sub/go.mod":              "module example.com/m/sub\n\ngo 1.24\n\ntool example.com/m/sub/cmd/gofumpt\n",
+		"sub/s.go":                "package sub\n",
+		"sub/cmd/gofumpt/main.go": standIn,
+	})
+
+	if err := repo.Gofumpt(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"args.txt":     "-extra -w cmd/gofumpt/main.go m.go",
+		"sub/args.txt": "-extra -w cmd/gofumpt/main.go s.go",
+	} {
+		args, err := afero.ReadFile(repo, name)
+		if err != nil {
+			t.Fatalf("declared tool was not run: %v", err)
+		}
+
+		if got := string(args); got != want {
+			t.Errorf("gofumpt ran with %q, want %q", got, want)
+		}
+	}
+}
+
+func TestGoimports_DeclaredTool(t *testing.T) {
+	// a stand-in for goimports that records the arguments of every run, one run per file
+	const standIn = `package main
+
+import (
+	"os"
+	"strings"
+)
+
+func main() {
+	f, err := os.OpenFile("args.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(strings.Join(os.Args[1:], " ") + "\n"); err != nil {
+		panic(err)
+	}
+}
+`
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":                    "module example.com/m\n\ngo 1.24\n\ntool example.com/m/cmd/goimports\n",
+		"m.go":                      "package m\n",
+		"cmd/goimports/main.go":     standIn,
+		"sub/go.mod":                "module example.com/m/sub\n\ngo 1.24\n\ntool example.com/m/sub/cmd/goimports\n",
+		"sub/s.go":                  "package sub\n",
+		"sub/cmd/goimports/main.go": standIn,
+	})
+
+	if err := repo.Goimports(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string][]string{
+		"args.txt":     {"-w cmd/goimports/main.go", "-w m.go"},
+		"sub/args.txt": {"-w cmd/goimports/main.go", "-w s.go"},
+	} {
+		args, err := afero.ReadFile(repo, name)
+		if err != nil {
+			t.Fatalf("declared tool was not run: %v", err)
+		}
+
+		got := strings.Split(strings.TrimSpace(string(args)), "\n")
+		slices.Sort(got)
+
+		if !slices.Equal(got, want) {
+			t.Errorf("goimports ran with %q, want %q", got, want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package gorepo
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// UpdatePolicy selects the dependency upgrades made by UpdateDependenciesWithPolicy.
+// The zero value upgrades every dependency to its latest minor or patch version, like `go get -u`.
+type UpdatePolicy struct {
+	// PatchOnly restricts upgrades to newer patch versions of the current minor version.
+	PatchOnly bool
+	// DirectOnly restricts upgrades to dependencies that are required directly.
+	DirectOnly bool
+	// Include restricts upgrades to the modules matching any of these patterns.
+	// Patterns are globs matching a module path or one of its prefixes, as in GOPRIVATE.
+	// If empty, all modules are included.
+	Include []string
+	// Exclude prevents upgrades of the modules matching any of these patterns.
+	Exclude []string
+	// Pin maps module paths to the version they must be set to. Pinned modules are never upgraded.
+	Pin map[string]string
+	// MinAge skips versions that were published less than this duration ago.
+	MinAge time.Duration
+}
+
+// ModuleChange is a change of a requirement in the go.mod of a module of the repository.
+type ModuleChange struct {
+	// Module is the module of the repository whose go.mod changed.
+	Module GoModule
+	// Path is the module path of the dependency.
+	Path string
+	// From is the previously required version, or empty if the requirement was added.
+	From string
+	// To is the newly required version, or empty if the requirement was removed.
+	To string
+}
+
+func (c ModuleChange) String() string {
+	switch {
+	case c.From == "":
+		return fmt.Sprintf("add %s %s", c.Path, c.To)
+	case c.To == "":
+		return fmt.Sprintf("remove %s %s", c.Path, c.From)
+	default:
+		return fmt.Sprintf("bump %s from %s to %s", c.Path, c.From, c.To)
+	}
+}
+
+// UpdateDependenciesWithPolicy upgrades the dependencies of every module as allowed by the policy,
+// tidies the modules and re-vendors those that have a vendor directory.
+// It returns the requirement changes it made, derived from go.mod before and after the upgrade.
+func (r Repository) UpdateDependenciesWithPolicy(ctx context.Context, p UpdatePolicy) ([]ModuleChange, error) {
+	changes := []ModuleChange{}
+	if err := r.forEachModule(func(m GoModule) error {
+		planned, err := r.planUpdates(ctx, m, p)
+		if err != nil {
+			return err
+		}
+
+		made, err := r.applyUpdates(ctx, m, planned, p.Pin)
+		changes = append(changes, made...)
+
+		return err
+	}); err != nil {
+		return changes, err
+	}
+
+	return changes, nil
+}
+
+// planUpdates returns the upgrades of the dependencies of the module that the policy allows,
+// including the changes needed to apply its pins.
+func (r Repository) planUpdates(ctx context.Context, m GoModule, p UpdatePolicy) ([]ModuleChange, error) {
+	mods, err := r.listModules(ctx, m.Dir, moduleModeEnv, "-u", "all")
+	if err != nil {
+		return nil, err
+	}
+
+	planned := []ModuleChange{}
+	for _, lm := range mods {
+		if lm.Main {
+			continue
+		}
+
+		if pin, ok := p.Pin[lm.Path]; ok {
+			if pin != lm.Version {
+				planned = append(planned, ModuleChange{Module: m, Path: lm.Path, From: lm.Version, To: pin})
+			}
+
+			continue
+		}
+
+		if lm.Update == nil || !p.allows(lm) {
+			continue
+		}
+
+		target, err := r.targetVersion(ctx, m.Dir, lm, p)
+		if err != nil {
+			return nil, err
+		}
+
+		if target != "" {
+			planned = append(planned, ModuleChange{Module: m, Path: lm.Path, From: lm.Version, To: target})
+		}
+	}
+
+	return planned, nil
+}
+
+// allows reports whether the policy allows upgrading the module at all.
+func (p UpdatePolicy) allows(lm listedModule) bool {
+	if p.DirectOnly && lm.Indirect {
+		return false
+	}
+
+	if len(p.Include) > 0 && !module.MatchPrefixPatterns(strings.Join(p.Include, ","), lm.Path) {
+		return false
+	}
+
+	return !module.MatchPrefixPatterns(strings.Join(p.Exclude, ","), lm.Path)
+}
+
+// targetVersion returns the newest version of the module the policy allows, or empty if there is none.
+func (r Repository) targetVersion(ctx context.Context, dir string, lm listedModule, p UpdatePolicy) (string, error) {
+	if !p.PatchOnly && p.MinAge == 0 {
+		return lm.Update.Version, nil // exactly what `go get -u` would choose
+	}
+
+	listed, err := r.listModules(ctx, dir, moduleModeEnv, "-versions", lm.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if len(listed) != 1 {
+		return "", fmt.Errorf("listing versions of %s: got %d modules", lm.Path, len(listed))
+	}
+
+	versions := candidateVersions(lm.Version, listed[0].Versions, p.PatchOnly)
+	if p.MinAge == 0 {
+		return newest(versions), nil
+	}
+
+	cutoff := time.Now().Add(-p.MinAge)
+	for _, v := range versions {
+		info, err := r.listModules(ctx, dir, moduleModeEnv, lm.Path+"@"+v)
+		if err != nil {
+			return "", err
+		}
+
+		if len(info) == 1 && info[0].Time != nil && info[0].Time.Before(cutoff) {
+			return v, nil
+		}
+	}
+
+	return "", nil
+}
+
+// candidateVersions returns the versions newer than current that are eligible as an upgrade, newest first.
+// Pre-releases are only eligible if the current version is a pre-release as well.
+func candidateVersions(current string, versions []string, patchOnly bool) []string {
+	candidates := []string{}
+	for _, v := range versions {
+		switch {
+		case semver.Compare(v, current) <= 0,
+			semver.Major(v) != semver.Major(current),
+			patchOnly && semver.MajorMinor(v) != semver.MajorMinor(current),
+			semver.Prerelease(v) != "" && semver.Prerelease(current) == "":
+			continue
+		}
+
+		candidates = append(candidates, v)
+	}
+
+	slices.SortFunc(candidates, func(a, b string) int { return semver.Compare(b, a) })
+
+	return candidates
+}
+
+// newest returns the first of the versions sorted newest first, or empty if there are none.
+func newest(versions []string) string {
+	if len(versions) == 0 {
+		return ""
+	}
+
+	return versions[0]
+}
+
+// applyUpdates runs `go get` for the planned upgrades, which include the changes needed to apply the pins,
+// together with the pins of the other modules the module requires,
+// tidies the module and re-vendors it if it has a vendor directory.
+// It returns the requirement changes actually made.
+func (r Repository) applyUpdates(ctx context.Context, m GoModule, planned []ModuleChange, pins map[string]string) ([]ModuleChange, error) {
+	if len(planned) == 0 {
+		return nil, nil
+	}
+
+	before, err := r.modFile(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"get"}
+	for _, c := range planned {
+		args = append(args, c.Path+"@"+c.To)
+	}
+
+	// hold the pinned modules the module requires at their versions, so that the upgrades cannot raise them,
+	// without adding requirements of pinned modules it does not depend on
+	required := requiredVersions(before)
+	for _, path := range slices.Sorted(maps.Keys(pins)) {
+		if _, ok := required[path]; ok && !slices.ContainsFunc(planned, func(c ModuleChange) bool { return c.Path == path }) {
+			args = append(args, path+"@"+pins[path])
+		}
+	}
+
+	if _, err := r.execEnvIn(ctx, m.Dir, moduleModeEnv, "go", args...); err != nil {
+		return nil, err
+	}
+
+	if _, err := r.execEnvIn(ctx, m.Dir, moduleModeEnv, "go", "mod", "tidy"); err != nil {
+		return nil, err
+	}
+
+	if err := r.revendor(ctx, m); err != nil {
+		return nil, err
+	}
+
+	after, err := r.modFile(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffRequirements(m, before, after), nil
+}
+
+// diffRequirements returns the changes of the requirements from one go.mod to another, sorted by module path.
+func diffRequirements(m GoModule, before, after *modfile.File) []ModuleChange {
+	from, to := requiredVersions(before), requiredVersions(after)
+
+	changes := []ModuleChange{}
+	for path, v := range from {
+		if to[path] != v {
+			changes = append(changes, ModuleChange{Module: m, Path: path, From: v, To: to[path]})
+		}
+	}
+
+	for path, v := range to {
+		if _, ok := from[path]; !ok {
+			changes = append(changes, ModuleChange{Module: m, Path: path, To: v})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b ModuleChange) int { return strings.Compare(a.Path, b.Path) })
+
+	return changes
+}
+
+func requiredVersions(f *modfile.File) map[string]string {
+	versions := make(map[string]string, len(f.Require))
+	for _, req := range f.Require {
+		versions[req.Mod.Path] = req.Mod.Version
+	}
+
+	return versions
+}
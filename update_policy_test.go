@@ -0,0 +1,163 @@
+package gorepo
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+)
+
+func TestCandidateVersions(t *testing.T) {
+	versions := []string{"v1.2.0", "v1.2.1", "v1.2.2", "v1.3.0", "v1.4.0-rc.1", "v2.0.0"}
+
+	if got, want := candidateVersions("v1.2.1", versions, false), []string{"v1.3.0", "v1.2.2"}; !slices.Equal(got, want) {
+		t.Errorf("minor: got %v, want %v", got, want)
+	}
+	if got, want := candidateVersions("v1.2.1", versions, true), []string{"v1.2.2"}; !slices.Equal(got, want) {
+		t.Errorf("patch only: got %v, want %v", got, want)
+	}
+	if got, want := candidateVersions("v1.4.0-beta.1", versions, false), []string{"v1.4.0-rc.1"}; !slices.Equal(got, want) {
+		t.Errorf("pre-release: got %v, want %v", got, want)
+	}
+}
+
+func TestUpdatePolicyAllows(t *testing.T) {
+	p := UpdatePolicy{
+		DirectOnly: true,
+		Include:    []string{"golang.org/x", "example.com/*"},
+		Exclude:    []string{"golang.org/x/net"},
+	}
+
+	tests := []struct {
+		mod  listedModule
+		want bool
+	}{
+		{listedModule{Path: "golang.org/x/mod"}, true},
+		{listedModule{Path: "golang.org/x/mod", Indirect: true}, false},
+		{listedModule{Path: "golang.org/x/net"}, false},
+		{listedModule{Path: "example.com/foo"}, true},
+		{listedModule{Path: "github.com/foo/bar"}, false},
+	}
+	for _, tt := range tests {
+		if got := p.allows(tt.mod); got != tt.want {
+			t.Errorf("allows(%+v) = %v, want %v", tt.mod, got, tt.want)
+		}
+	}
+}
+
+func TestDiffRequirements(t *testing.T) {
+	before, err := modfile.Parse("go.mod", []byte(`module example.com/test
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0 // indirect
+)
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := modfile.Parse("go.mod", []byte(`module example.com/test
+
+require (
+	example.com/a v1.1.0
+	example.com/b v1.0.0
+	example.com/d v0.1.0 // indirect
+)
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := GoModule{Dir: ".", Path: "example.com/test"}
+	got := diffRequirements(m, before, after)
+	want := []ModuleChange{
+		{Module: m, Path: "example.com/a", From: "v1.0.0", To: "v1.1.0"},
+		{Module: m, Path: "example.com/c", From: "v1.0.0"},
+		{Module: m, Path: "example.com/d", To: "v0.1.0"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diffRequirements() = %v, want %v", got, want)
+	}
+
+	for i, s := range []string{
+		"bump example.com/a from v1.0.0 to v1.1.0",
+		"remove example.com/c v1.0.0",
+		"add example.com/d v0.1.0",
+	} {
+		if got[i].String() != s {
+			t.Errorf("String() = %q, want %q", got[i].String(), s)
+		}
+	}
+}
+
+func TestUpdateDependenciesWithPolicy_Pin(t *testing.T) {
+	writeTestProxy(t, map[string][]string{
+		"example.com/foo": {"v1.0.0", "v1.0.1", "v1.1.0"},
+		"example.com/bar": {"v1.0.0", "v1.1.0"},
+	}, nil)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":       "module example.com/m\n\ngo 1.24\n\nrequire (\n\texample.com/foo v1.0.0\n\texample.com/local v1.0.0\n)\n\nreplace example.com/local => ./local\n",
+		"m.go":         "package m\n\nimport (\n\t_ \"example.com/foo\"\n\t_ \"example.com/local\"\n)\n",
+		"local/go.mod": "module example.com/local\n\ngo 1.24\n",
+		"local/l.go":   "package local\n",
+		"sub/go.mod":   "module example.com/m/sub\n\ngo 1.24\n\nrequire example.com/bar v1.0.0\n",
+		"sub/s.go":     "package sub\n\nimport _ \"example.com/bar\"\n",
+	})
+
+	// the pin is the only change of the root module
+	changes, err := repo.UpdateDependenciesWithPolicy(context.Background(), UpdatePolicy{
+		// the proxy does not know the replaced module, so pinning it in a module that does not require it fails
+		Pin: map[string]string{"example.com/foo": "v1.0.1", "example.com/local": "v1.0.0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, c := range changes {
+		got = append(got, c.Module.Dir+": "+c.String())
+	}
+
+	// the sub module does not depend on the pinned module, so it is not added there
+	want := []string{".: bump example.com/foo from v1.0.0 to v1.0.1", "sub: bump example.com/bar from v1.0.0 to v1.1.0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+}
+
+func TestUpdateDependenciesWithPolicy_Workspace(t *testing.T) {
+	writeTestProxy(t, map[string][]string{
+		"example.com/foo": {"v1.0.0", "v1.1.0"},
+		"example.com/bar": {"v1.0.0", "v1.1.0"},
+	}, nil)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.work":  "go 1.24\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.24\n\nrequire example.com/foo v1.0.0\n",
+		"a/a.go":   "package a\n\nimport _ \"example.com/foo\"\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.24\n\nrequire example.com/bar v1.0.0\n",
+		"b/b.go":   "package b\n\nimport _ \"example.com/bar\"\n",
+	})
+
+	changes, err := repo.UpdateDependenciesWithPolicy(context.Background(), UpdatePolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, c := range changes {
+		got = append(got, c.Module.Dir+": "+c.String())
+	}
+
+	// each module is planned from its own build list, not the one shared by the workspace
+	want := []string{"a: bump example.com/foo from v1.0.0 to v1.1.0", "b: bump example.com/bar from v1.0.0 to v1.1.0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+}
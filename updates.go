@@ -0,0 +1,263 @@
+package gorepo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxMajorProbes limits the concurrent queries for newer major versions.
+const maxMajorProbes = 8
+
+// UpdateKind classifies a module upgrade by the semantic version component that changes.
+type UpdateKind int
+
+const (
+	// UpdateNone means that no newer version is available.
+	UpdateNone UpdateKind = iota
+	// UpdatePatch is an upgrade within the same minor version.
+	UpdatePatch
+	// UpdateMinor is an upgrade within the same major version.
+	UpdateMinor
+	// UpdateMajor is an upgrade to a newer major version.
+	UpdateMajor
+)
+
+func (k UpdateKind) String() string {
+	switch k {
+	case UpdateNone:
+		return "none"
+	case UpdatePatch:
+		return "patch"
+	case UpdateMinor:
+		return "minor"
+	case UpdateMajor:
+		return "major"
+	default:
+		return "UpdateKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// ModuleUpdate describes an available upgrade of a module required by a module of the repository.
+type ModuleUpdate struct {
+	// Module is the module of the repository that requires the dependency.
+	Module GoModule
+	// Path is the module path of the dependency.
+	Path string
+	// Version is the currently selected version.
+	Version string
+	// Latest is the latest available version within the major version of Version, or empty if there is none.
+	Latest string
+	// Time is the publication time of the latest version, if known.
+	Time time.Time
+	// LatestMajorPath is the module path of the newest major version published under a /vN module path,
+	// e.g. "example.com/foo/v3", or empty if there is none. Only direct dependencies are probed.
+	LatestMajorPath string
+	// LatestMajor is the latest version of LatestMajorPath, or empty if there is none.
+	LatestMajor string
+	// Indirect is true if the dependency is not required directly by the module.
+	Indirect bool
+	// Deprecated is the deprecation message of the dependency, if any.
+	Deprecated string
+	// Retracted is true if the current version has been retracted by its author.
+	Retracted bool
+	// Kind is the kind of the upgrade from Version to Latest.
+	// An upgrade to LatestMajor is a major upgrade regardless.
+	Kind UpdateKind
+}
+
+// listedModule is a module as printed by `go list -m -json`.
+type listedModule struct {
+	Path       string
+	Version    string
+	Versions   []string
+	Time       *time.Time
+	Update     *listedModule
+	Replace    *listedModule
+	Main       bool
+	Indirect   bool
+	Dir        string
+	GoMod      string
+	Zip        string
+	Sum        string
+	GoVersion  string
+	Deprecated string
+	Retracted  []string
+	Error      *struct{ Err string }
+}
+
+// AvailableUpdates lists the dependencies of every module that can be upgraded,
+// have been deprecated, or whose selected version has been retracted.
+//
+// In addition to the upgrades `go get -u` would make, it detects newer major versions
+// of direct dependencies that are published under a /vN module path, reported separately as LatestMajor.
+func (r Repository) AvailableUpdates(ctx context.Context) ([]ModuleUpdate, error) {
+	updates := []ModuleUpdate{}
+	if err := r.forEachModule(func(m GoModule) error {
+		ups, err := r.availableUpdates(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		updates = append(updates, ups...)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return updates, nil
+}
+
+func (r Repository) availableUpdates(ctx context.Context, m GoModule) ([]ModuleUpdate, error) {
+	mods, err := r.listModules(ctx, m.Dir, moduleModeEnv, "-u", "all")
+	if err != nil {
+		return nil, err
+	}
+
+	updates := []ModuleUpdate{}
+	for _, lm := range mods {
+		if lm.Main {
+			continue
+		}
+
+		up := ModuleUpdate{
+			Module:     m,
+			Path:       lm.Path,
+			Version:    lm.Version,
+			Indirect:   lm.Indirect,
+			Deprecated: lm.Deprecated,
+			Retracted:  len(lm.Retracted) > 0,
+		}
+
+		if lm.Update != nil {
+			up.Latest = lm.Update.Version
+			up.Kind = updateKind(lm.Version, lm.Update.Version)
+			if lm.Update.Time != nil {
+				up.Time = *lm.Update.Time
+			}
+		}
+
+		updates = append(updates, up)
+	}
+
+	// probe for newer major versions of direct dependencies, each goroutine updating its own entry
+	eg := errgroup.Group{}
+	eg.SetLimit(maxMajorProbes)
+
+	for i := range updates {
+		if updates[i].Indirect {
+			continue
+		}
+
+		eg.Go(func() error {
+			major, ok := r.latestMajor(ctx, m.Dir, updates[i].Path, updates[i].Version)
+			if !ok {
+				return nil
+			}
+
+			updates[i].LatestMajorPath = major.Path
+			updates[i].LatestMajor = major.Version
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	// only keep entries that are actionable
+	result := updates[:0]
+	for _, up := range updates {
+		if up.Kind != UpdateNone || up.LatestMajor != "" || up.Deprecated != "" || up.Retracted {
+			result = append(result, up)
+		}
+	}
+
+	return result, nil
+}
+
+// listModules runs `go list -m -json` with the given arguments in dir, adding env to the environment.
+// Pass moduleModeEnv to list the build list of the module itself rather than that of its workspace.
+func (r Repository) listModules(ctx context.Context, dir string, env []string, args ...string) ([]listedModule, error) {
+	out, err := r.outputEnvIn(ctx, dir, env, "go", append([]string{"list", "-m", "-json"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeJSONStream[listedModule](out)
+}
+
+// latestMajor queries the latest version of the newest major version after the given one,
+// e.g. example.com/foo/v3 for example.com/foo at v1.2.3 if both /v2 and /v3 exist.
+// Any error (typically that the module does not exist) ends the search.
+func (r Repository) latestMajor(ctx context.Context, dir, path, version string) (listedModule, bool) {
+	latest, found := listedModule{}, false
+
+	for {
+		next, ok := nextMajorPath(path, version)
+		if !ok {
+			return latest, found
+		}
+
+		mods, err := r.listModules(ctx, dir, moduleModeEnv, next+"@latest")
+		if err != nil || len(mods) != 1 || mods[0].Error != nil ||
+			semver.Major(mods[0].Version) != semver.Major(nextMajorVersion(version)) {
+			return latest, found
+		}
+
+		latest, found = mods[0], true
+		path, version = mods[0].Path, mods[0].Version
+	}
+}
+
+// nextMajorPath returns the module path of the major version following the given version of the module.
+func nextMajorPath(path, version string) (string, bool) {
+	prefix, pathMajor, ok := module.SplitPathVersion(path)
+	if !ok {
+		return "", false
+	}
+
+	next := strings.TrimPrefix(semver.Major(nextMajorVersion(version)), "v")
+	if next == "" {
+		return "", false
+	}
+
+	if strings.HasPrefix(pathMajor, ".") { // gopkg.in/yaml.v3
+		return prefix + ".v" + next, true
+	}
+
+	return prefix + "/v" + next, true
+}
+
+// nextMajorVersion returns the first version of the major version following the given version.
+// Versions v0 and v1 share the module path without suffix, so the next major version for both is v2.
+func nextMajorVersion(version string) string {
+	major, err := strconv.Atoi(strings.TrimPrefix(semver.Major(version), "v"))
+	if err != nil {
+		return ""
+	}
+
+	return fmt.Sprintf("v%d.0.0", max(major, 1)+1)
+}
+
+// updateKind classifies the upgrade from one version to another.
+func updateKind(from, to string) UpdateKind {
+	switch {
+	case !semver.IsValid(from) || !semver.IsValid(to) || semver.Compare(from, to) >= 0:
+		return UpdateNone
+	case semver.Major(from) != semver.Major(to):
+		return UpdateMajor
+	case semver.MajorMinor(from) != semver.MajorMinor(to):
+		return UpdateMinor
+	default:
+		return UpdatePatch
+	}
+}
@@ -0,0 +1,215 @@
+package gorepo
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestUpdateKind(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     UpdateKind
+	}{
+		{"v1.2.3", "v1.2.4", UpdatePatch},
+		{"v1.2.3", "v1.3.0", UpdateMinor},
+		{"v1.2.3", "v2.0.0", UpdateMajor},
+		{"v1.2.3", "v1.2.3", UpdateNone},
+		{"v1.2.3", "v1.2.2", UpdateNone},
+		{"v0.0.0-20240101000000-abcdefabcdef", "v0.1.0", UpdateMinor},
+		{"", "v1.0.0", UpdateNone},
+	}
+	for _, tt := range tests {
+		if got := updateKind(tt.from, tt.to); got != tt.want {
+			t.Errorf("updateKind(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestNextMajorPath(t *testing.T) {
+	tests := []struct {
+		path, version string
+		want          string
+	}{
+		{"example.com/foo", "v1.2.3", "example.com/foo/v2"},
+		{"example.com/foo", "v0.4.0", "example.com/foo/v2"},
+		{"example.com/foo/v2", "v2.1.0", "example.com/foo/v3"},
+		{"example.com/foo", "v3.0.0+incompatible", "example.com/foo/v4"},
+		{"gopkg.in/yaml.v3", "v3.0.1", "gopkg.in/yaml.v4"},
+	}
+	for _, tt := range tests {
+		got, ok := nextMajorPath(tt.path, tt.version)
+		if !ok || got != tt.want {
+			t.Errorf("nextMajorPath(%q, %q) = %q, %v, want %q", tt.path, tt.version, got, ok, tt.want)
+		}
+	}
+}
+
+func TestDecodeListedModules(t *testing.T) {
+	const out = `{
+	"Path": "example.com/test",
+	"Main": true
+}
+{
+	"Path": "example.com/a",
+	"Version": "v1.0.0",
+	"Update": {"Path": "example.com/a", "Version": "v1.1.0", "Time": "2026-01-02T03:04:05Z"},
+	"Indirect": true,
+	"Deprecated": "use example.com/b",
+	"Retracted": ["broken"]
+}
+`
+	mods, err := decodeJSONStream[listedModule]([]byte(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mods) != 2 {
+		t.Fatalf("got %d modules, want 2", len(mods))
+	}
+
+	a := mods[1]
+	if a.Update == nil || a.Update.Version != "v1.1.0" || a.Update.Time == nil {
+		t.Errorf("unexpected update: %+v", a.Update)
+	}
+	if !a.Indirect || a.Deprecated != "use example.com/b" || len(a.Retracted) != 1 {
+		t.Errorf("unexpected module: %+v", a)
+	}
+}
+
+// writeTestProxy writes a module proxy serving the given versions of the modules, by module path,
+// with the requirements of module versions, by path@version, e.g. "example.com/b v1.1.0",
+// and configures the go command to use it with a fresh module cache. It returns the proxy directory.
+func writeTestProxy(t *testing.T, versions, requires map[string][]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for path, vs := range versions {
+		escaped, err := module.EscapePath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		vdir := filepath.Join(dir, filepath.FromSlash(escaped), "@v")
+		if err := os.MkdirAll(vdir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, v := range vs {
+			gomod := "module " + path + "\n\ngo 1.24\n"
+			for _, req := range requires[path+"@"+v] {
+				gomod += "\nrequire " + req + "\n"
+			}
+
+			var buf bytes.Buffer
+
+			zw := zip.NewWriter(&buf)
+			for name, content := range map[string]string{"go.mod": gomod, "p.go": "package p\n"} {
+				w, err := zw.Create(path + "@" + v + "/" + name)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if _, err := io.WriteString(w, content); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			for ext, data := range map[string][]byte{
+				".info": []byte(`{"Version":"` + v + `","Time":"2024-01-01T00:00:00Z"}`),
+				".mod":  []byte(gomod),
+				".zip":  buf.Bytes(),
+			} {
+				if err := os.WriteFile(filepath.Join(vdir, v+ext), data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		if err := os.WriteFile(filepath.Join(vdir, "list"), []byte(strings.Join(vs, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(dir))
+	t.Setenv("GOFLAGS", "-mod=mod -modcacherw")
+	t.Setenv("GOMODCACHE", t.TempDir())
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GOSUMDB", "off")
+	t.Setenv("GOWORK", "")
+
+	return dir
+}
+
+func TestAvailableUpdates(t *testing.T) {
+	writeTestProxy(t, map[string][]string{
+		"example.com/foo":    {"v1.0.0", "v1.0.1", "v1.1.0"},
+		"example.com/foo/v2": {"v2.0.0", "v2.1.0"},
+	}, nil)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.24\n\nrequire example.com/foo v1.0.0\n",
+		"m.go":   "package m\n\nimport _ \"example.com/foo\"\n",
+	})
+
+	updates, err := repo.AvailableUpdates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updates) != 1 {
+		t.Fatalf("got %d updates, want 1: %+v", len(updates), updates)
+	}
+
+	// the in-major upgrade is kept next to the newer major version
+	up := updates[0]
+	if up.Path != "example.com/foo" || up.Latest != "v1.1.0" || up.Kind != UpdateMinor {
+		t.Errorf("unexpected update %+v", up)
+	}
+
+	if up.LatestMajorPath != "example.com/foo/v2" || up.LatestMajor != "v2.1.0" {
+		t.Errorf("unexpected major update %q %q", up.LatestMajorPath, up.LatestMajor)
+	}
+}
+
+func TestAvailableUpdates_Workspace(t *testing.T) {
+	writeTestProxy(t, map[string][]string{
+		"example.com/foo": {"v1.0.0", "v1.1.0"},
+		"example.com/bar": {"v1.0.0", "v1.1.0"},
+	}, nil)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.work":  "go 1.24\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.24\n\nrequire example.com/foo v1.0.0\n",
+		"a/a.go":   "package a\n\nimport _ \"example.com/foo\"\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.24\n\nrequire example.com/bar v1.0.0\n",
+		"b/b.go":   "package b\n\nimport _ \"example.com/bar\"\n",
+	})
+
+	updates, err := repo.AvailableUpdates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, up := range updates {
+		got = append(got, up.Module.Dir+": "+up.Path)
+	}
+
+	if want := []string{"a: example.com/foo", "b: example.com/bar"}; !slices.Equal(got, want) {
+		t.Errorf("got updates %q, want %q", got, want)
+	}
+}
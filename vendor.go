@@ -0,0 +1,542 @@
+package gorepo
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/MarkRosemaker/ghrepo"
+	"github.com/spf13/afero"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// vendoredModule is a module listed in vendor/modules.txt.
+type vendoredModule struct {
+	Path    string
+	Version string
+	// Replace is the replacement of the module, if any.
+	Replace *module.Version
+	// Explicit is true if the module is required in go.mod.
+	Explicit bool
+	// GoVersion is the go version declared by the module.
+	GoVersion string
+	// Packages are the vendored packages of the module.
+	Packages []string
+}
+
+// vendorModules returns the modules listed in vendor/modules.txt of the module in dir.
+// It returns false if the module is not vendored.
+func (r Repository) vendorModules(dir string) ([]vendoredModule, bool, error) {
+	data, err := afero.ReadFile(r, filepath.Join(dir, "vendor", "modules.txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("reading vendor/modules.txt: %w", err)
+	}
+
+	mods, err := parseVendorModules(data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return mods, true, nil
+}
+
+// parseVendorModules parses vendor/modules.txt as written by `go mod vendor`:
+//
+//	# example.com/a v1.0.0 => example.com/b v1.1.0
+//	## explicit; go 1.22
+//	example.com/a/pkg
+func parseVendorModules(data []byte) ([]vendoredModule, error) {
+	mods := []vendoredModule{}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "## "):
+			if len(mods) == 0 {
+				return nil, fmt.Errorf("annotation %q before first module", line)
+			}
+
+			m := &mods[len(mods)-1]
+			for ann := range strings.SplitSeq(strings.TrimPrefix(line, "## "), ";") {
+				ann = strings.TrimSpace(ann)
+				if ann == "explicit" {
+					m.Explicit = true
+				} else if v, ok := strings.CutPrefix(ann, "go "); ok {
+					m.GoVersion = v
+				}
+			}
+		case strings.HasPrefix(line, "# "):
+			m, err := parseVendorModuleLine(strings.TrimPrefix(line, "# "))
+			if err != nil {
+				return nil, err
+			}
+
+			mods = append(mods, m)
+		case strings.HasPrefix(line, "#"):
+			return nil, fmt.Errorf("invalid vendor/modules.txt line %q", line)
+		default:
+			if len(mods) == 0 {
+				return nil, fmt.Errorf("package %q before first module", line)
+			}
+
+			mods[len(mods)-1].Packages = append(mods[len(mods)-1].Packages, line)
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("scanning vendor/modules.txt: %w", err)
+	}
+
+	return mods, nil
+}
+
+func parseVendorModuleLine(line string) (vendoredModule, error) {
+	mod, repl, replaced := strings.Cut(line, " => ")
+
+	fields := strings.Fields(mod)
+	if len(fields) == 0 || len(fields) > 2 {
+		return vendoredModule{}, fmt.Errorf("invalid vendor/modules.txt module %q", line)
+	}
+
+	m := vendoredModule{Path: fields[0]}
+	if len(fields) == 2 {
+		m.Version = fields[1]
+	}
+
+	if replaced {
+		fields := strings.Fields(repl)
+		if len(fields) == 0 || len(fields) > 2 {
+			return vendoredModule{}, fmt.Errorf("invalid vendor/modules.txt replacement %q", line)
+		}
+
+		m.Replace = &module.Version{Path: fields[0]}
+		if len(fields) == 2 {
+			m.Replace.Version = fields[1]
+		}
+	}
+
+	return m, nil
+}
+
+// VendorDiscrepancyKind classifies a VendorDiscrepancy.
+type VendorDiscrepancyKind int
+
+const (
+	// VendorMissingModule is a module required in go.mod that is not listed in vendor/modules.txt.
+	VendorMissingModule VendorDiscrepancyKind = iota + 1
+	// VendorExtraModule is a module listed as explicitly required in vendor/modules.txt that go.mod does not require.
+	VendorExtraModule
+	// VendorVersionMismatch is a module whose version or replacement differs between go.mod and vendor/modules.txt.
+	VendorVersionMismatch
+	// VendorModifiedFile is a vendored file whose content differs from the module zip.
+	VendorModifiedFile
+	// VendorUnknownFile is a vendored file that does not exist in the module zip.
+	VendorUnknownFile
+	// VendorChecksumMismatch is a module zip in the module cache whose hash differs from go.sum.
+	VendorChecksumMismatch
+	// VendorVerifyFailed means that `go mod verify` reported a problem.
+	VendorVerifyFailed
+)
+
+func (k VendorDiscrepancyKind) String() string {
+	switch k {
+	case VendorMissingModule:
+		return "missing module"
+	case VendorExtraModule:
+		return "extra module"
+	case VendorVersionMismatch:
+		return "version mismatch"
+	case VendorModifiedFile:
+		return "modified file"
+	case VendorUnknownFile:
+		return "unknown file"
+	case VendorChecksumMismatch:
+		return "checksum mismatch"
+	case VendorVerifyFailed:
+		return "verify failed"
+	default:
+		return "VendorDiscrepancyKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// VendorDiscrepancy is a difference between the vendor directory of a module and what `go mod vendor` would produce.
+type VendorDiscrepancy struct {
+	// Module is the module of the repository whose vendor directory is affected.
+	Module GoModule
+	// Kind classifies the discrepancy.
+	Kind VendorDiscrepancyKind
+	// Path is the module path of the dependency, if any.
+	Path string
+	// Version is the version of the dependency, if any.
+	Version string
+	// File is the affected file relative to the repository root, if any.
+	File string
+	// Detail describes the discrepancy.
+	Detail string
+}
+
+func (d VendorDiscrepancy) String() string {
+	s := d.Kind.String()
+	if d.Path != "" {
+		s += " " + d.Path
+		if d.Version != "" {
+			s += "@" + d.Version
+		}
+	}
+
+	if d.File != "" {
+		s += " " + d.File
+	}
+
+	if d.Detail != "" {
+		s += ": " + d.Detail
+	}
+
+	return s
+}
+
+// VerifyVendor checks the vendor directory of every vendored module:
+// vendor/modules.txt must agree with go.mod, `go mod verify` must succeed,
+// and every vendored file must be identical to the one in the module zip,
+// whose hash is first checked against go.sum.
+//
+// Only files directly in the directories of vendored packages and module roots are compared,
+// since `go mod vendor` does not copy subdirectories other than those of packages.
+// Modules replaced by local directories are not compared.
+func (r Repository) VerifyVendor(ctx context.Context) ([]VendorDiscrepancy, error) {
+	discrepancies := []VendorDiscrepancy{}
+	if err := r.forEachModule(func(m GoModule) error {
+		found, err := r.verifyVendor(ctx, m)
+		if err != nil {
+			return err
+		}
+
+		discrepancies = append(discrepancies, found...)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return discrepancies, nil
+}
+
+func (r Repository) verifyVendor(ctx context.Context, m GoModule) ([]VendorDiscrepancy, error) {
+	vendored, ok, err := r.vendorModules(m.Dir)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	f, err := r.modFile(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := compareVendorModules(m, f, vendored)
+
+	if _, err := r.execIn(ctx, m.Dir, "go", "mod", "verify"); err != nil {
+		detail := err.Error()
+		if execErr := (ghrepo.ExecError{}); errors.As(err, &execErr) {
+			detail = execErr.Out
+		}
+
+		discrepancies = append(discrepancies, VendorDiscrepancy{Module: m, Kind: VendorVerifyFailed, Detail: detail})
+	}
+
+	sums, err := r.goSums(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	zips, err := r.moduleZips(ctx, m.Dir, vendored)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vm := range vendored {
+		mod := vm.source()
+		zipPath, ok := zips[mod]
+		if !ok {
+			continue
+		}
+
+		found, err := r.compareVendoredFiles(m, vm, mod, zipPath, sums[mod])
+		if err != nil {
+			return nil, err
+		}
+
+		discrepancies = append(discrepancies, found...)
+	}
+
+	return discrepancies, nil
+}
+
+// source returns the module version the vendored files were copied from, taking replacements into account.
+func (vm vendoredModule) source() module.Version {
+	if vm.Replace != nil {
+		return *vm.Replace
+	}
+
+	return module.Version{Path: vm.Path, Version: vm.Version}
+}
+
+// compareVendorModules compares the requirements and replacements of go.mod with vendor/modules.txt.
+func compareVendorModules(m GoModule, f *modfile.File, vendored []vendoredModule) []VendorDiscrepancy {
+	discrepancies := []VendorDiscrepancy{}
+
+	byPath := map[string]vendoredModule{}
+	for _, vm := range vendored {
+		if vm.Version != "" {
+			byPath[vm.Path] = vm
+		}
+	}
+
+	for _, req := range f.Require {
+		vm, ok := byPath[req.Mod.Path]
+		switch {
+		case !ok:
+			discrepancies = append(discrepancies, VendorDiscrepancy{
+				Module: m, Kind: VendorMissingModule, Path: req.Mod.Path, Version: req.Mod.Version,
+				Detail: "required in go.mod but not in vendor/modules.txt",
+			})
+		case vm.Version != req.Mod.Version:
+			discrepancies = append(discrepancies, VendorDiscrepancy{
+				Module: m, Kind: VendorVersionMismatch, Path: req.Mod.Path, Version: req.Mod.Version,
+				Detail: "vendor/modules.txt has " + vm.Version,
+			})
+		case !vm.Explicit:
+			discrepancies = append(discrepancies, VendorDiscrepancy{
+				Module: m, Kind: VendorMissingModule, Path: req.Mod.Path, Version: req.Mod.Version,
+				Detail: "required in go.mod but not marked explicit in vendor/modules.txt",
+			})
+		}
+
+		if ok {
+			if want, got := goModReplacement(f, req.Mod), vm.Replace; !equalReplacement(want, got) {
+				discrepancies = append(discrepancies, VendorDiscrepancy{
+					Module: m, Kind: VendorVersionMismatch, Path: req.Mod.Path, Version: req.Mod.Version,
+					Detail: fmt.Sprintf("replaced by %s in go.mod but by %s in vendor/modules.txt",
+						replacementString(want), replacementString(got)),
+				})
+			}
+		}
+	}
+
+	required := requiredVersions(f)
+	for _, vm := range vendored {
+		if _, ok := required[vm.Path]; vm.Explicit && !ok {
+			discrepancies = append(discrepancies, VendorDiscrepancy{
+				Module: m, Kind: VendorExtraModule, Path: vm.Path, Version: vm.Version,
+				Detail: "listed as explicit in vendor/modules.txt but not required in go.mod",
+			})
+		}
+	}
+
+	return discrepancies
+}
+
+// goModReplacement returns the replacement of the module version in go.mod, if any.
+// A replacement of the specific version takes precedence over one of all versions.
+func goModReplacement(f *modfile.File, mod module.Version) *module.Version {
+	var repl *module.Version
+	for _, r := range f.Replace {
+		if r.Old.Path != mod.Path {
+			continue
+		}
+
+		if r.Old.Version == mod.Version {
+			return &r.New
+		} else if r.Old.Version == "" {
+			repl = &r.New
+		}
+	}
+
+	return repl
+}
+
+func equalReplacement(a, b *module.Version) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func replacementString(v *module.Version) string {
+	switch {
+	case v == nil:
+		return "nothing"
+	case v.Version == "":
+		return v.Path
+	default:
+		return v.String()
+	}
+}
+
+// goSums returns the h1 hashes of the module zips listed in go.sum.
+func (r Repository) goSums(dir string) (map[module.Version]string, error) {
+	data, err := afero.ReadFile(r, filepath.Join(dir, "go.sum"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[module.Version]string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading go.sum: %w", err)
+	}
+
+	sums := map[module.Version]string{}
+	for line := range strings.Lines(string(data)) {
+		fields := strings.Fields(line)
+		if len(fields) != 3 || strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+
+		sums[module.Version{Path: fields[0], Version: fields[1]}] = fields[2]
+	}
+
+	return sums, nil
+}
+
+// moduleZips downloads the zips of the vendored modules, if needed, and returns their paths in the module cache.
+func (r Repository) moduleZips(ctx context.Context, dir string, vendored []vendoredModule) (map[module.Version]string, error) {
+	args := []string{"mod", "download", "-json"}
+	for _, vm := range vendored {
+		if src := vm.source(); len(vm.Packages) > 0 && src.Version != "" {
+			args = append(args, src.String())
+		}
+	}
+
+	zips := map[module.Version]string{}
+	if len(args) == 3 {
+		return zips, nil
+	}
+
+	out, err := r.outputIn(ctx, dir, "go", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	downloaded, err := decodeJSONStream[listedModule](out)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range downloaded {
+		if d.Error != nil {
+			return nil, fmt.Errorf("downloading %s@%s: %s", d.Path, d.Version, d.Error.Err)
+		}
+
+		zips[module.Version{Path: d.Path, Version: d.Version}] = d.Zip
+	}
+
+	return zips, nil
+}
+
+// compareVendoredFiles compares the vendored files of a module with the module zip,
+// after checking the hash of the zip against go.sum.
+func (r Repository) compareVendoredFiles(m GoModule, vm vendoredModule, mod module.Version, zipPath, sum string) ([]VendorDiscrepancy, error) {
+	if hash, err := dirhash.HashZip(zipPath, dirhash.Hash1); err != nil {
+		return nil, fmt.Errorf("hashing module zip: %w", err)
+	} else if hash != sum {
+		return []VendorDiscrepancy{{
+			Module: m, Kind: VendorChecksumMismatch, Path: mod.Path, Version: mod.Version,
+			Detail: fmt.Sprintf("module zip has %s, go.sum has %q", hash, sum),
+		}}, nil
+	}
+
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening module zip: %w", err)
+	}
+	defer zr.Close() //nolint:errcheck
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, zf := range zr.File {
+		files[zf.Name] = zf
+	}
+
+	// directories of the vendored packages and the module root, relative to the module root
+	dirs := []string{"."}
+	for _, pkg := range vm.Packages {
+		if rel, ok := strings.CutPrefix(pkg, vm.Path+"/"); ok && !slices.Contains(dirs, rel) {
+			dirs = append(dirs, rel)
+		}
+	}
+
+	discrepancies := []VendorDiscrepancy{}
+	for _, dir := range dirs {
+		vendorDir := filepath.Join(m.Dir, "vendor", filepath.FromSlash(vm.Path), filepath.FromSlash(dir))
+
+		infos, err := afero.ReadDir(r, vendorDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", vendorDir, err)
+		}
+
+		for _, info := range infos {
+			if info.IsDir() {
+				continue
+			}
+
+			name := filepath.Join(vendorDir, info.Name())
+			d := VendorDiscrepancy{Module: m, Path: vm.Path, Version: vm.Version, File: name}
+
+			zf, ok := files[mod.String()+"/"+path.Join(dir, info.Name())]
+			if !ok {
+				d.Kind, d.Detail = VendorUnknownFile, "not in module zip"
+				discrepancies = append(discrepancies, d)
+
+				continue
+			}
+
+			same, err := r.sameContent(name, zf)
+			if err != nil {
+				return nil, err
+			}
+
+			if !same {
+				d.Kind, d.Detail = VendorModifiedFile, "differs from module zip"
+				discrepancies = append(discrepancies, d)
+			}
+		}
+	}
+
+	return discrepancies, nil
+}
+
+// sameContent reports whether the file in the repository has the same content as the zip entry.
+func (r Repository) sameContent(name string, zf *zip.File) (bool, error) {
+	vendored, err := afero.ReadFile(r, name)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	rc, err := zf.Open()
+	if err != nil {
+		return false, fmt.Errorf("opening %s in module zip: %w", zf.Name, err)
+	}
+	defer rc.Close() //nolint:errcheck
+
+	original, err := io.ReadAll(rc)
+	if err != nil {
+		return false, fmt.Errorf("reading %s in module zip: %w", zf.Name, err)
+	}
+
+	return bytes.Equal(vendored, original), nil
+}
@@ -0,0 +1,197 @@
+package gorepo
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+func TestParseVendorModules(t *testing.T) {
+	mods, err := parseVendorModules([]byte(`# example.com/a v1.0.0
+## explicit; go 1.22
+example.com/a
+example.com/a/sub
+# example.com/b v0.1.0 => example.com/fork v0.1.1
+## explicit
+example.com/b
+# example.com/c v1.2.0
+## go 1.21
+# example.com/d => ../d
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mods) != 4 {
+		t.Fatalf("got %d modules, want 4", len(mods))
+	}
+
+	if a := mods[0]; a.Path != "example.com/a" || a.Version != "v1.0.0" || !a.Explicit ||
+		a.GoVersion != "1.22" || len(a.Packages) != 2 {
+		t.Errorf("unexpected module %+v", a)
+	}
+	if b := mods[1]; b.Replace == nil || *b.Replace != (module.Version{Path: "example.com/fork", Version: "v0.1.1"}) {
+		t.Errorf("unexpected replacement %+v", b.Replace)
+	}
+	if c := mods[2]; c.Explicit || len(c.Packages) != 0 {
+		t.Errorf("unexpected module %+v", c)
+	}
+	if d := mods[3]; d.Version != "" || d.Replace == nil || d.Replace.Path != "../d" {
+		t.Errorf("unexpected module %+v", d)
+	}
+}
+
+func TestParseVendorModules_Invalid(t *testing.T) {
+	for _, data := range []string{
+		"example.com/a\n",
+		"## explicit\n",
+		"#invalid\n",
+		"# a b c\n",
+	} {
+		if _, err := parseVendorModules([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+}
+
+func TestParseVendorModules_Repository(t *testing.T) {
+	data, err := os.ReadFile("vendor/modules.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := parseVendorModules(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareVendorModules(t *testing.T) {
+	f, err := modfile.Parse("go.mod", []byte(`module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.1.0
+	example.com/c v1.0.0
+	example.com/d v1.0.0
+)
+
+replace example.com/d => example.com/fork v1.0.1
+`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vendored, err := parseVendorModules([]byte(`# example.com/a v1.0.0
+## explicit
+example.com/a
+# example.com/b v1.0.0
+## explicit
+example.com/b
+# example.com/d v1.0.0
+## explicit
+example.com/d
+# example.com/e v1.0.0
+## explicit
+example.com/e
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := compareVendorModules(GoModule{Dir: "."}, f, vendored)
+
+	want := []struct {
+		kind VendorDiscrepancyKind
+		path string
+	}{
+		{VendorVersionMismatch, "example.com/b"},
+		{VendorMissingModule, "example.com/c"},
+		{VendorVersionMismatch, "example.com/d"},
+		{VendorExtraModule, "example.com/e"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d discrepancies, want %d: %v", len(got), len(want), got)
+	}
+
+	for i, w := range want {
+		if got[i].Kind != w.kind || got[i].Path != w.path {
+			t.Errorf("discrepancy %d = %v, want %v %s", i, got[i], w.kind, w.path)
+		}
+	}
+}
+
+func TestCompareVendoredFiles_Checksum(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range map[string]string{
+		"example.com/a@v1.0.0/go.mod": "hello\n",
+		"example.com/a@v1.0.0/a.go":   "package a\n",
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "v1.0.0.zip")
+	if err := os.WriteFile(zipPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := newTestRepo(t)
+	m := GoModule{Dir: ".", Path: "example.com/m"}
+	vm := vendoredModule{Path: "example.com/a", Version: "v1.0.0"}
+	mod := module.Version{Path: "example.com/a", Version: "v1.0.0"}
+
+	const sum = "h1:eJHOhCXRjM1JyfngRAGTtgg/DbWgW4r2OSFBm1PFU2g="
+	if got, err := repo.compareVendoredFiles(m, vm, mod, zipPath, sum); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 0 {
+		t.Errorf("unexpected discrepancies %v", got)
+	}
+
+	got, err := repo.compareVendoredFiles(m, vm, mod, zipPath, "h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 || got[0].Kind != VendorChecksumMismatch {
+		t.Errorf("got %v, want a checksum mismatch", got)
+	}
+}
+
+func TestVerifyVendor(t *testing.T) {
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":             "module example.com/m\n\ngo 1.22\n",
+		"m.go":               "package m\n",
+		"vendor/modules.txt": "# example.com/a v1.0.0\n## explicit\n",
+		"sub/go.mod":         "module example.com/m/sub\n\ngo 1.22\n",
+	})
+
+	got, err := repo.VerifyVendor(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d discrepancies, want 1: %v", len(got), got)
+	}
+
+	if d := got[0]; d.Module.Dir != "." || d.Kind != VendorExtraModule || d.Path != "example.com/a" {
+		t.Errorf("unexpected discrepancy %v", d)
+	}
+}
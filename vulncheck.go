@@ -0,0 +1,248 @@
+package gorepo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// VulncheckOptions configures Vulncheck.
+type VulncheckOptions struct {
+	// DB is the URL of the vulnerability database, e.g. "file:///srv/vulndb" for a local mirror.
+	// If empty, govulncheck uses its default, https://vuln.go.dev.
+	DB string
+}
+
+// Vulnerability is a known vulnerability affecting a module of the repository, as found by govulncheck.
+type Vulnerability struct {
+	// Module is the module of the repository that is affected.
+	Module GoModule
+	// ID is the OSV identifier, e.g. "GO-2024-2687".
+	ID string
+	// Aliases are other identifiers of the vulnerability, e.g. CVE numbers.
+	Aliases []string
+	// Summary is a short description of the vulnerability.
+	Summary string
+	// Path is the path of the vulnerable module, or "stdlib" for the standard library.
+	Path string
+	// Version is the version of the vulnerable module in use.
+	Version string
+	// FixedVersion is the lowest version that fixes the vulnerability, or empty if there is no fix.
+	FixedVersion string
+	// Reachable is true if a vulnerable symbol is called by the code of the module,
+	// as opposed to the vulnerable package only being imported or the vulnerable module only being required.
+	Reachable bool
+	// Stacks are the call stacks through which vulnerable symbols are reached.
+	// Each starts at the vulnerable symbol and ends at the entry point in the module.
+	Stacks [][]StackFrame
+}
+
+// StackFrame is an entry of a call stack reported by govulncheck.
+type StackFrame struct {
+	Module   string
+	Version  string
+	Package  string
+	Function string
+	Receiver string
+	File     string
+	Line     int
+	Column   int
+}
+
+func (f StackFrame) String() string {
+	name := f.Function
+	if f.Receiver != "" {
+		name = f.Receiver + "." + name
+	}
+
+	if f.Package != "" {
+		name = f.Package + "." + name
+	}
+
+	if f.File == "" {
+		return name
+	}
+
+	return fmt.Sprintf("%s (%s:%d:%d)", name, f.File, f.Line, f.Column)
+}
+
+// vulncheckMessage is a message of the JSON stream printed by `govulncheck -json`.
+type vulncheckMessage struct {
+	OSV     *vulncheckOSV     `json:"osv"`
+	Finding *vulncheckFinding `json:"finding"`
+}
+
+type vulncheckOSV struct {
+	ID      string   `json:"id"`
+	Aliases []string `json:"aliases"`
+	Summary string   `json:"summary"`
+}
+
+type vulncheckFinding struct {
+	OSV          string `json:"osv"`
+	FixedVersion string `json:"fixed_version"`
+	Trace        []struct {
+		Module   string `json:"module"`
+		Version  string `json:"version"`
+		Package  string `json:"package"`
+		Function string `json:"function"`
+		Receiver string `json:"receiver"`
+		Position *struct {
+			Filename string `json:"filename"`
+			Line     int    `json:"line"`
+			Column   int    `json:"column"`
+		} `json:"position"`
+	} `json:"trace"`
+}
+
+// Vulncheck runs govulncheck on every module of the repository and returns the vulnerabilities found.
+// The govulncheck binary must be installed.
+func (r Repository) Vulncheck(ctx context.Context, opts VulncheckOptions) ([]Vulnerability, error) {
+	args := []string{"-json"}
+	if opts.DB != "" {
+		args = append(args, "-db", opts.DB)
+	}
+
+	args = append(args, "./...")
+
+	vulns := []Vulnerability{}
+	if err := r.forEachModule(func(m GoModule) error {
+		out, err := r.outputIn(ctx, m.Dir, "govulncheck", args...)
+		if err != nil {
+			return err
+		}
+
+		found, err := parseVulncheck(m, out)
+		if err != nil {
+			return err
+		}
+
+		vulns = append(vulns, found...)
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return vulns, nil
+}
+
+// parseVulncheck parses the JSON output of govulncheck and merges the findings
+// per vulnerability and vulnerable module.
+func parseVulncheck(m GoModule, out []byte) ([]Vulnerability, error) {
+	msgs, err := decodeJSONStream[vulncheckMessage](out)
+	if err != nil {
+		return nil, fmt.Errorf("parsing govulncheck output: %w", err)
+	}
+
+	osvs := map[string]*vulncheckOSV{}
+	for _, msg := range msgs {
+		if msg.OSV != nil {
+			osvs[msg.OSV.ID] = msg.OSV
+		}
+	}
+
+	vulns := []Vulnerability{}
+	for _, msg := range msgs {
+		f := msg.Finding
+		if f == nil || len(f.Trace) == 0 {
+			continue
+		}
+
+		vulnerable := f.Trace[0]
+
+		i := slices.IndexFunc(vulns, func(v Vulnerability) bool {
+			return v.ID == f.OSV && v.Path == vulnerable.Module
+		})
+		if i < 0 {
+			v := Vulnerability{
+				Module:       m,
+				ID:           f.OSV,
+				Path:         vulnerable.Module,
+				Version:      vulnerable.Version,
+				FixedVersion: f.FixedVersion,
+			}
+
+			if osv, ok := osvs[f.OSV]; ok {
+				v.Aliases = osv.Aliases
+				v.Summary = osv.Summary
+			}
+
+			vulns = append(vulns, v)
+			i = len(vulns) - 1
+		}
+
+		if vulnerable.Function == "" {
+			continue // found on module or package level only
+		}
+
+		stack := make([]StackFrame, 0, len(f.Trace))
+		for _, fr := range f.Trace {
+			sf := StackFrame{
+				Module:   fr.Module,
+				Version:  fr.Version,
+				Package:  fr.Package,
+				Function: fr.Function,
+				Receiver: fr.Receiver,
+			}
+
+			if fr.Position != nil {
+				sf.File, sf.Line, sf.Column = fr.Position.Filename, fr.Position.Line, fr.Position.Column
+			}
+
+			stack = append(stack, sf)
+		}
+
+		vulns[i].Reachable = true
+		vulns[i].Stacks = append(vulns[i].Stacks, stack)
+	}
+
+	return vulns, nil
+}
+
+// FixVulnerabilities upgrades each vulnerable module to the version that fixes its vulnerabilities,
+// tidies the affected modules of the repository and re-vendors those that have a vendor directory.
+// Vulnerabilities without a fix and those of the standard library are skipped.
+// It returns the requirement changes it made.
+func (r Repository) FixVulnerabilities(ctx context.Context, vulns []Vulnerability) ([]ModuleChange, error) {
+	planned := map[GoModule][]ModuleChange{}
+	for _, v := range vulns {
+		if v.FixedVersion == "" || v.Path == "stdlib" || v.Path == "toolchain" {
+			continue
+		}
+
+		changes := planned[v.Module]
+		if i := slices.IndexFunc(changes, func(c ModuleChange) bool { return c.Path == v.Path }); i >= 0 {
+			changes[i].To = semver.Max(changes[i].To, v.FixedVersion)
+			continue
+		}
+
+		planned[v.Module] = append(changes, ModuleChange{
+			Module: v.Module,
+			Path:   v.Path,
+			From:   v.Version,
+			To:     v.FixedVersion,
+		})
+	}
+
+	mods := make([]GoModule, 0, len(planned))
+	for m := range planned {
+		mods = append(mods, m)
+	}
+
+	slices.SortFunc(mods, func(a, b GoModule) int { return strings.Compare(a.Dir, b.Dir) })
+
+	changes := []ModuleChange{}
+	for _, m := range mods {
+		made, err := r.applyUpdates(ctx, m, planned[m], nil)
+		changes = append(changes, made...)
+		if err != nil {
+			return changes, ModuleError{Module: m, Err: err}
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,88 @@
+package gorepo
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+const testVulncheckOutput = `{"config":{"protocol_version":"v1.0.0","scanner_name":"govulncheck","db":"file:///srv/vulndb"}}
+{"progress":{"message":"Scanning your code and 12 packages across 3 dependent modules for known vulnerabilities..."}}
+{"osv":{"id":"GO-2023-1571","aliases":["CVE-2022-41723"],"summary":"Denial of service via crafted HTTP/2 stream"}}
+{"osv":{"id":"GO-2024-0001","summary":"Unused vulnerability"}}
+{"finding":{"osv":"GO-2023-1571","fixed_version":"v0.7.0","trace":[{"module":"golang.org/x/net","version":"v0.6.0"}]}}
+{"finding":{"osv":"GO-2023-1571","fixed_version":"v0.7.0","trace":[{"module":"golang.org/x/net","version":"v0.6.0","package":"golang.org/x/net/http2/hpack"}]}}
+{"finding":{"osv":"GO-2023-1571","fixed_version":"v0.7.0","trace":[{"module":"golang.org/x/net","version":"v0.6.0","package":"golang.org/x/net/http2/hpack","receiver":"*Decoder","function":"DecodeFull","position":{"filename":"hpack.go","line":138,"column":19}},{"module":"example.com/test","package":"example.com/test","function":"main","position":{"filename":"main.go","line":12,"column":2}}]}}
+{"finding":{"osv":"GO-2024-0001","trace":[{"module":"example.com/other","version":"v1.0.0"}]}}
+`
+
+func TestParseVulncheck(t *testing.T) {
+	m := GoModule{Dir: ".", Path: "example.com/test"}
+	vulns, err := parseVulncheck(m, []byte(testVulncheckOutput))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vulns) != 2 {
+		t.Fatalf("got %d vulnerabilities, want 2: %+v", len(vulns), vulns)
+	}
+
+	v := vulns[0]
+	if v.ID != "GO-2023-1571" || v.Path != "golang.org/x/net" || v.Version != "v0.6.0" || v.FixedVersion != "v0.7.0" {
+		t.Errorf("unexpected vulnerability: %+v", v)
+	}
+	if v.Summary == "" || len(v.Aliases) != 1 {
+		t.Errorf("OSV details missing: %+v", v)
+	}
+	if !v.Reachable || len(v.Stacks) != 1 || len(v.Stacks[0]) != 2 {
+		t.Fatalf("expected one reachable stack: %+v", v)
+	}
+	if got, want := v.Stacks[0][0].String(), "golang.org/x/net/http2/hpack.*Decoder.DecodeFull (hpack.go:138:19)"; got != want {
+		t.Errorf("frame = %q, want %q", got, want)
+	}
+
+	if vulns[1].Reachable || vulns[1].FixedVersion != "" {
+		t.Errorf("expected unreachable vulnerability without fix: %+v", vulns[1])
+	}
+}
+
+func TestFixVulnerabilities(t *testing.T) {
+	writeTestProxy(t, map[string][]string{
+		"example.com/foo": {"v1.0.0", "v1.0.1", "v1.1.0", "v1.2.0"},
+		"example.com/bar": {"v1.0.0", "v1.1.0"},
+	}, nil)
+
+	repo := newTestRepo(t)
+	writeTestFiles(t, repo, map[string]string{
+		"go.mod":     "module example.com/m\n\ngo 1.24\n\nrequire example.com/foo v1.0.0\n",
+		"m.go":       "package m\n\nimport _ \"example.com/foo\"\n",
+		"sub/go.mod": "module example.com/m/sub\n\ngo 1.24\n\nrequire example.com/bar v1.0.0\n",
+		"sub/s.go":   "package sub\n\nimport _ \"example.com/bar\"\n",
+	})
+
+	root := GoModule{Dir: ".", Path: "example.com/m"}
+	sub := GoModule{Dir: "sub", Path: "example.com/m/sub"}
+
+	// go get would fail for the standard library and the toolchain, which cannot be upgraded like modules
+	changes, err := repo.FixVulnerabilities(context.Background(), []Vulnerability{
+		{Module: root, ID: "GO-1", Path: "example.com/foo", Version: "v1.0.0", FixedVersion: "v1.0.1"},
+		{Module: root, ID: "GO-2", Path: "example.com/foo", Version: "v1.0.0", FixedVersion: "v1.1.0"},
+		{Module: root, ID: "GO-3", Path: "example.com/foo", Version: "v1.0.0", FixedVersion: "v1.0.1"},
+		{Module: root, ID: "GO-4", Path: "stdlib", Version: "v1.24.0", FixedVersion: "v1.24.1"},
+		{Module: root, ID: "GO-5", Path: "toolchain", Version: "v1.24.0", FixedVersion: "v1.24.1"},
+		{Module: sub, ID: "GO-6", Path: "example.com/bar", Version: "v1.0.0"}, // no fix
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []string{}
+	for _, c := range changes {
+		got = append(got, c.Module.Dir+": "+c.String())
+	}
+
+	// the findings for the same module are merged to the highest fixed version, not the latest version
+	if want := []string{".: bump example.com/foo from v1.0.0 to v1.1.0"}; !slices.Equal(got, want) {
+		t.Errorf("got changes %q, want %q", got, want)
+	}
+}